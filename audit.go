@@ -0,0 +1,294 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultAuditMaxSizeMB default size after which the audit log is rotated.
+	defaultAuditMaxSizeMB = 100
+	// defaultAuditMaxAge default age after which the audit log is rotated.
+	defaultAuditMaxAge = 24 * time.Hour
+	// defaultAuditQueueSize default number of entries waiting to be written.
+	defaultAuditQueueSize = 1024
+	// auditDroppedCounter counts the entries dropped because the queue was full.
+	auditDroppedCounter = "auditDropped"
+	// auditBackupTimeFormat suffix of rotated audit logs. It sorts chronologically.
+	auditBackupTimeFormat = "20060102T150405.000000000"
+)
+
+// AuditConfig configures the audit log of blocked requests.
+type AuditConfig struct {
+	// Path of the audit log. The audit log is disabled when empty.
+	Path string `json:"path,omitempty"`
+	// MaxSizeMB is the size in megabytes after which the audit log is rotated.
+	MaxSizeMB int64 `json:"maxSizeMB,omitempty"` //nolint:tagliatelle
+	// MaxAge is the age after which the audit log is rotated, such as "24h".
+	MaxAge string `json:"maxAge,omitempty"`
+	// MaxBackups is the number of rotated audit logs kept. All are kept when 0.
+	MaxBackups int `json:"maxBackups,omitempty"`
+	// QueueSize is the number of entries waiting to be written. Entries are dropped when the queue is full.
+	QueueSize int `json:"queueSize,omitempty"`
+	// TruncateIP truncates client IPs to their /24 (IPv4) or /48 (IPv6) network.
+	TruncateIP bool `json:"truncateIP,omitempty"` //nolint:tagliatelle
+}
+
+// auditEntry a line of the audit log.
+type auditEntry struct {
+	Time     string `json:"time"`
+	Name     string `json:"name"`
+	Action   string `json:"action"`
+	Rule     string `json:"rule"`
+	Status   int    `json:"status"`
+	IP       string `json:"ip"`
+	Country  string `json:"country"`
+	ASN      string `json:"asn"`
+	Host     string `json:"host"`
+	Path     string `json:"path"`
+	Location string `json:"location,omitempty"`
+}
+
+// auditItem a queued line. Items with a done channel flush the queue instead.
+type auditItem struct {
+	line []byte
+	done chan struct{}
+}
+
+// auditSink writes the audit log from a bounded queue, so a slow disk never blocks requests.
+type auditSink struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	queue      chan auditItem
+	now        func() time.Time
+
+	// Owned by the writing goroutine.
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+var (
+	// auditSinks are shared by path, as Traefik creates new middlewares on every configuration reload.
+	auditSinks   = map[string]*auditSink{}
+	auditSinksMu sync.Mutex
+)
+
+// newAuditSink validates the config and creates a sink that is not writing yet.
+func newAuditSink(cfg AuditConfig) (*auditSink, error) {
+	s := &auditSink{
+		path:       cfg.Path,
+		maxSize:    cfg.MaxSizeMB * 1024 * 1024,
+		maxAge:     defaultAuditMaxAge,
+		maxBackups: cfg.MaxBackups,
+		now:        time.Now,
+	}
+	if cfg.MaxSizeMB < 0 || cfg.MaxBackups < 0 || cfg.QueueSize < 0 {
+		return nil, fmt.Errorf("audit max size, max backups and queue size must not be negative")
+	}
+	if s.maxSize == 0 {
+		s.maxSize = defaultAuditMaxSizeMB * 1024 * 1024
+	}
+	if cfg.MaxAge != "" {
+		var err error
+		if s.maxAge, err = time.ParseDuration(cfg.MaxAge); err != nil || s.maxAge <= 0 {
+			return nil, fmt.Errorf("invalid audit max age: maxAge=%s", cfg.MaxAge)
+		}
+	}
+	queueSize := cfg.QueueSize
+	if queueSize == 0 {
+		queueSize = defaultAuditQueueSize
+	}
+	s.queue = make(chan auditItem, queueSize)
+
+	return s, nil
+}
+
+// openAuditSink returns the running sink of the config's path, creating and starting it if needed.
+// Returns nil when the audit log is disabled. The first config of a path decides its rotation and queue size.
+func openAuditSink(cfg AuditConfig) (*auditSink, error) {
+	if cfg.Path == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	path, err := filepath.Abs(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	cfg.Path = path
+
+	auditSinksMu.Lock()
+	defer auditSinksMu.Unlock()
+
+	if s, ok := auditSinks[path]; ok {
+		return s, nil
+	}
+
+	s, err := newAuditSink(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	go s.run()
+
+	auditSinks[path] = s
+	return s, nil
+}
+
+// enqueue queues a line without blocking. Returns false when the queue is full.
+func (s *auditSink) enqueue(line []byte) bool {
+	select {
+	case s.queue <- auditItem{line: line}:
+		return true
+	default:
+		return false
+	}
+}
+
+// flush waits for the queued lines to be written.
+func (s *auditSink) flush() {
+	done := make(chan struct{})
+	s.queue <- auditItem{done: done}
+	<-done
+}
+
+// run writes the queued lines.
+func (s *auditSink) run() {
+	for item := range s.queue {
+		if item.done != nil {
+			close(item.done)
+			continue
+		}
+		if err := s.write(item.line); err != nil {
+			log.Printf("[geoip] unable to write audit log: path=%s, err=%v", s.path, err)
+		}
+	}
+}
+
+// open opens the audit log for appending.
+func (s *auditSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("%w", err)
+	}
+
+	s.file = file
+	s.size = info.Size()
+	s.openedAt = s.now()
+	return nil
+}
+
+// write appends a line, rotating the audit log first when it is too large or too old.
+func (s *auditSink) write(line []byte) error {
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if s.size > 0 && (s.size+int64(len(line)) > s.maxSize || s.now().Sub(s.openedAt) >= s.maxAge) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// rotate renames the current audit log, opens a new one and drops the oldest backups.
+func (s *auditSink) rotate() error {
+	_ = s.file.Close()
+	s.file = nil
+
+	if err := os.Rename(s.path, s.path+"."+s.now().UTC().Format(auditBackupTimeFormat)); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+
+	if s.maxBackups > 0 {
+		backups, err := filepath.Glob(s.path + ".*")
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+		sort.Strings(backups)
+		for len(backups) > s.maxBackups {
+			_ = os.Remove(backups[0])
+			backups = backups[1:]
+		}
+	}
+	return nil
+}
+
+// truncateIP keeps the /24 of IPv4 and the /48 of IPv6 addresses.
+func truncateIP(ip net.IP) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32))
+	}
+	return ip.Mask(net.CIDRMask(48, 128))
+}
+
+// audit records an enforced deny or redirect decision.
+func (mw *TraefikGeoIP) audit(req *http.Request, dec *decision) {
+	if mw.auditSink == nil || (dec.action != decisionDeny && dec.action != decisionRedirect) {
+		return
+	}
+
+	entry := auditEntry{
+		Time:     mw.auditSink.now().UTC().Format(time.RFC3339Nano),
+		Name:     mw.name,
+		Action:   dec.action,
+		Rule:     dec.rule,
+		Status:   dec.status,
+		Country:  Unknown,
+		ASN:      Unknown,
+		Host:     req.Host,
+		Path:     req.URL.Path,
+		Location: dec.location,
+	}
+	if dec.ip != nil {
+		ip := dec.ip
+		if mw.auditTruncateIP {
+			ip = truncateIP(ip)
+		}
+		entry.IP = ip.String()
+	}
+	if dec.result != nil {
+		entry.Country = dec.result.countryCode
+		entry.ASN = dec.result.asn
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		if mw.debug {
+			log.Printf("[geoip] unable to encode audit entry: name=%s, err=%v", mw.name, err)
+		}
+		return
+	}
+
+	if !mw.auditSink.enqueue(append(line, '\n')) {
+		mw.metrics.inc(auditDroppedCounter)
+	}
+}
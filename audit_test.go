@@ -0,0 +1,141 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	cfg := CreateConfig()
+	cfg.Audit = AuditConfig{Path: path, TruncateIP: true}
+	cfg.Rules = []RuleConfig{
+		{Name: "deny-admin", PathPrefixes: []string{"/admin"}, Action: RuleActionDeny},
+		{Name: "report-shop", PathPrefixes: []string{"/shop"}, Action: RuleActionDeny, Mode: ModeReport},
+	}
+	cfg.Redirects = map[string]string{"DE": "https://de.example.com{path}"}
+	result := munichResult()
+	result.asn = "3320"
+	instance := newTestMiddleware(t, cfg, result)
+
+	recorder, _ := serveRequest(instance, "http://example.com/admin")
+	assertStatus(t, recorder, http.StatusForbidden)
+	recorder, _ = serveRequest(instance, "http://example.com/shop")
+	assertStatus(t, recorder, http.StatusFound)
+	instance.auditSink.flush()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Error opening audit log %v", err)
+	}
+	defer file.Close()
+
+	entries := []auditEntry{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := auditEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid audit line '%s'", scanner.Text())
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %v", entries)
+	}
+	denied := entries[0]
+	if denied.Action != decisionDeny || denied.Rule != "deny-admin" || denied.Status != http.StatusForbidden ||
+		denied.IP != "188.193.88.0" || denied.Country != "DE" || denied.ASN != "3320" ||
+		denied.Host != "example.com" || denied.Path != "/admin" || denied.Name != "traefik_geoip" || denied.Time == "" {
+		t.Fatalf("invalid deny entry %+v", denied)
+	}
+	redirected := entries[1]
+	if redirected.Action != decisionRedirect || redirected.Rule != "redirect:DE" || redirected.Location != "https://de.example.com/shop" {
+		t.Fatalf("invalid redirect entry %+v", redirected)
+	}
+
+	// The same path shares the running sink.
+	other := newTestMiddleware(t, cfg, result)
+	if other.auditSink != instance.auditSink {
+		t.Fatalf("audit sinks must be shared by path")
+	}
+}
+
+func TestAuditRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := newAuditSink(AuditConfig{Path: path, MaxAge: "1h", MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+	sink.maxSize = 10
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	sink.now = func() time.Time { return now }
+
+	write := func(line string) {
+		t.Helper()
+		if err := sink.write([]byte(line)); err != nil {
+			t.Fatalf("Error writing %v", err)
+		}
+		now = now.Add(time.Second)
+	}
+
+	write("aaaa\n")
+	write("bbbb\n")
+	// Over the size limit.
+	write("cccc\n")
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 1 {
+		t.Fatalf("expected 1 backup, got %v", backups)
+	}
+
+	// Over the age limit.
+	now = now.Add(time.Hour)
+	write("dddd\n")
+	write("eeee\n")
+	write("ffff\n")
+	backups, _ = filepath.Glob(path + ".*")
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %v", backups)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "ffff\n" {
+		t.Fatalf("invalid current audit log '%s'", data)
+	}
+	if data, _ := os.ReadFile(backups[1]); string(data) != "dddd\neeee\n" {
+		t.Fatalf("invalid last backup '%s'", data)
+	}
+}
+
+func TestAuditQueueFull(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Rules = []RuleConfig{{Name: "deny-all", Action: RuleActionDeny}}
+	instance := newTestMiddleware(t, cfg, munichResult())
+
+	// A sink that is not running never drains its queue.
+	sink, err := newAuditSink(AuditConfig{Path: filepath.Join(t.TempDir(), "audit.log"), QueueSize: 1})
+	if err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+	instance.auditSink = sink
+
+	for i := 0; i < 3; i++ {
+		recorder, _ := serveRequest(instance, "http://example.com/")
+		assertStatus(t, recorder, http.StatusForbidden)
+	}
+	if instance.Metrics()[auditDroppedCounter] != 2 {
+		t.Fatalf("invalid metrics %v", instance.Metrics())
+	}
+
+	if _, err := newAuditSink(AuditConfig{Path: "audit.log", MaxAge: "soon"}); err == nil {
+		t.Fatalf("Must fail on invalid max age")
+	}
+	if ip := truncateIP(net.ParseIP("2001:db8:1234:5678::1")); ip.String() != "2001:db8:1234::" {
+		t.Fatalf("invalid truncated IP %v", ip)
+	}
+}
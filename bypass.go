@@ -0,0 +1,213 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// BypassHeader header with the ID of the bypass token used by the request.
+	BypassHeader = "GeoIP-Bypass"
+	// defaultBypassTokenHeader default header carrying bypass tokens.
+	defaultBypassTokenHeader = "GeoIP-Bypass-Token"
+	// defaultBypassTokenCookie default cookie carrying bypass tokens.
+	defaultBypassTokenCookie = "geoip_bypass"
+)
+
+var (
+	errInvalidBypassToken = errors.New("invalid bypass token")
+	errExpiredBypassToken = errors.New("expired bypass token")
+	errBypassTokenScope   = errors.New("bypass token out of scope")
+)
+
+// BypassConfig configures signed tokens that bypass geo blocking.
+type BypassConfig struct {
+	// Secret used to sign the tokens. Bypass is disabled when empty.
+	Secret string `json:"secret,omitempty"`
+	// TokenHeader is the request header carrying the token.
+	TokenHeader string `json:"tokenHeader,omitempty"`
+	// TokenCookie is the cookie carrying the token.
+	TokenCookie string `json:"tokenCookie,omitempty"`
+}
+
+// BypassClaims the content of a bypass token.
+type BypassClaims struct {
+	// ID identifies the token in audit headers and logs.
+	ID string `json:"id"`
+	// ExpiresAt is the Unix time after which the token is rejected.
+	ExpiresAt int64 `json:"exp"`
+	// Host limits the token to a host. A leading "*." matches any subdomain.
+	Host string `json:"host,omitempty"`
+	// PathPrefix limits the token to paths with the prefix.
+	PathPrefix string `json:"path,omitempty"`
+}
+
+// signBypassPayload signs the encoded claims.
+func signBypassPayload(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// NewBypassToken creates a token with the claims, signed with the secret.
+// Tokens are made of the base64url encoded JSON claims and their HMAC-SHA256, separated by a dot.
+func NewBypassToken(secret string, claims BypassClaims) (string, error) {
+	if secret == "" || claims.ID == "" || claims.ExpiresAt == 0 {
+		return "", fmt.Errorf("secret, ID and expiry are required")
+	}
+
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("%w", err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	return payload + "." + signBypassPayload(secret, payload), nil
+}
+
+// verifyBypassToken checks the signature, expiry and scope of a token.
+func verifyBypassToken(secret, token string, req *http.Request, now time.Time) (*BypassClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, errInvalidBypassToken
+	}
+	if !hmac.Equal([]byte(parts[1]), []byte(signBypassPayload(secret, parts[0]))) {
+		return nil, errInvalidBypassToken
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errInvalidBypassToken
+	}
+	claims := &BypassClaims{}
+	if err := json.Unmarshal(data, claims); err != nil || claims.ID == "" {
+		return nil, errInvalidBypassToken
+	}
+
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, errExpiredBypassToken
+	}
+
+	host := strings.ToLower(req.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if claims.Host != "" && !hostMatches(host, strings.ToLower(claims.Host)) {
+		return nil, errBypassTokenScope
+	}
+	if claims.PathPrefix != "" && !pathHasPrefix(req.URL.Path, claims.PathPrefix) {
+		return nil, errBypassTokenScope
+	}
+
+	return claims, nil
+}
+
+// pathHasPrefix checks if the path is the prefix or below it, so "/admin" matches "/admin/users" but not "/administrator".
+func pathHasPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// removeCookie removes a cookie's pairs from the request's Cookie headers.
+// The other pairs are kept as sent, including the ones net/http can not parse.
+func removeCookie(req *http.Request, name string) {
+	headers := req.Header.Values("Cookie")
+	kept := make([]string, 0, len(headers))
+	removed := false
+	for _, header := range headers {
+		parts := strings.Split(header, ";")
+		pairs := make([]string, 0, len(parts))
+		for _, part := range parts {
+			pairName, _, _ := strings.Cut(part, "=")
+			if strings.TrimSpace(pairName) == name {
+				removed = true
+				continue
+			}
+			pairs = append(pairs, part)
+		}
+		if value := strings.TrimLeft(strings.Join(pairs, ";"), " "); value != "" {
+			kept = append(kept, value)
+		}
+	}
+	if !removed {
+		return
+	}
+
+	req.Header.Del("Cookie")
+	for _, value := range kept {
+		req.Header.Add("Cookie", value)
+	}
+}
+
+// bypass verifies the bypass tokens of requests.
+type bypass struct {
+	secret      string
+	tokenHeader string
+	tokenCookie string
+	now         func() time.Time
+}
+
+// newBypass creates the verifier. Returns nil when bypass is disabled.
+func newBypass(cfg BypassConfig) *bypass {
+	if cfg.Secret == "" {
+		return nil
+	}
+
+	b := &bypass{
+		secret:      cfg.Secret,
+		tokenHeader: cfg.TokenHeader,
+		tokenCookie: cfg.TokenCookie,
+		now:         time.Now,
+	}
+	if b.tokenHeader == "" {
+		b.tokenHeader = defaultBypassTokenHeader
+	}
+	if b.tokenCookie == "" {
+		b.tokenCookie = defaultBypassTokenCookie
+	}
+	return b
+}
+
+// checkBypass returns the ID of the request's valid bypass token, or an empty string.
+// The token header and cookie are removed from the request, and the ID is set in the BypassHeader for auditing.
+func (mw *TraefikGeoIP) checkBypass(req *http.Request) string {
+	b := mw.bypass
+	if b == nil {
+		return ""
+	}
+
+	// Never trust a bypass header sent by the client.
+	req.Header.Del(BypassHeader)
+
+	token := req.Header.Get(b.tokenHeader)
+	req.Header.Del(b.tokenHeader)
+	if cookie, err := req.Cookie(b.tokenCookie); err == nil {
+		if token == "" {
+			token = cookie.Value
+		}
+		removeCookie(req, b.tokenCookie)
+	}
+	if token == "" {
+		return ""
+	}
+
+	claims, err := verifyBypassToken(b.secret, token, req, b.now())
+	if err != nil {
+		if mw.debug {
+			log.Printf("[geoip] bypass token rejected: name=%s, err=%v", mw.name, err)
+		}
+		return ""
+	}
+
+	req.Header.Set(BypassHeader, claims.ID)
+	return claims.ID
+}
@@ -0,0 +1,112 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBypassToken(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Rules = []RuleConfig{{Name: "deny-de", Countries: []string{"DE"}, Action: RuleActionDeny}}
+	cfg.Bypass.Secret = "s3cret"
+
+	var forwarded *http.Request
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) { forwarded = req })
+	instance, err := newMiddleware(next, cfg, "traefik_geoip", staticLookup(munichResult()))
+	if err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	token, err := NewBypassToken("s3cret", BypassClaims{ID: "qa-team", ExpiresAt: expiresAt, Host: "*.example.com", PathPrefix: "/shop"})
+	if err != nil {
+		t.Fatalf("Error creating token %v", err)
+	}
+	expired, _ := NewBypassToken("s3cret", BypassClaims{ID: "old", ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+	forged, _ := NewBypassToken("other", BypassClaims{ID: "forged", ExpiresAt: expiresAt})
+
+	serve := func(url, header, cookie string) *httptest.ResponseRecorder {
+		t.Helper()
+		forwarded = nil
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.RemoteAddr = "188.193.88.199:9999"
+		req.Header.Set(BypassHeader, "spoofed")
+		if header != "" {
+			req.Header.Set(defaultBypassTokenHeader, header)
+		}
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+			req.AddCookie(&http.Cookie{Name: defaultBypassTokenCookie, Value: cookie})
+		}
+		recorder := httptest.NewRecorder()
+		instance.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	assertStatus(t, serve("http://www.example.com/shop/cart", token, ""), http.StatusOK)
+	if forwarded.Header.Get(BypassHeader) != "qa-team" || forwarded.Header.Get(CountryCodeHeader) != "DE" {
+		t.Fatalf("bypassed requests must be enriched and marked")
+	}
+	if forwarded.Header.Get(defaultBypassTokenHeader) != "" {
+		t.Fatalf("bypass token must not be forwarded")
+	}
+	assertStatus(t, serve("http://www.example.com/shop", "", token), http.StatusOK)
+	if forwarded.Header.Get("Cookie") != "session=abc" {
+		t.Fatalf("bypass cookie must not be forwarded, got '%s'", forwarded.Header.Get("Cookie"))
+	}
+
+	assertStatus(t, serve("http://www.example.com/admin", token, ""), http.StatusForbidden)
+	assertStatus(t, serve("http://www.example.com/shopping", token, ""), http.StatusForbidden)
+	assertStatus(t, serve("http://example.org/shop", token, ""), http.StatusForbidden)
+	assertStatus(t, serve("http://www.example.com/shop", expired, ""), http.StatusForbidden)
+	assertStatus(t, serve("http://www.example.com/shop", forged, ""), http.StatusForbidden)
+	assertStatus(t, serve("http://www.example.com/shop", token+"x", ""), http.StatusForbidden)
+
+	if instance.Metrics()["bypass-deny;rule=deny-de"] != 2 {
+		t.Fatalf("invalid metrics %v", instance.Metrics())
+	}
+}
+
+func TestBypassSpoofedHeader(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Bypass.Secret = "s3cret"
+	instance := newTestMiddleware(t, cfg, munichResult())
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.RemoteAddr = "188.193.88.199:9999"
+	req.Header.Set(BypassHeader, "spoofed")
+	instance.ServeHTTP(httptest.NewRecorder(), req)
+	if req.Header.Get(BypassHeader) != "" {
+		t.Fatalf("client bypass header must be removed")
+	}
+
+	if _, err := NewBypassToken("", BypassClaims{ID: "x", ExpiresAt: 1}); err == nil {
+		t.Fatalf("Must fail without secret")
+	}
+}
+
+func TestRemoveCookie(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Add("Cookie", `geoip_bypass=token; note=a\b;theme="dark"`)
+	req.Header.Add("Cookie", "geoip_bypass=again")
+	req.Header.Add("Cookie", "session=abc;  geoip_bypass=token")
+	for _, cookie := range req.Cookies() {
+		if cookie.Name == "note" {
+			t.Fatalf("the cookie must be one net/http rejects")
+		}
+	}
+
+	// Only the bypass cookie's pairs are removed, the others are kept as sent.
+	removeCookie(req, defaultBypassTokenCookie)
+	cookies := req.Header.Values("Cookie")
+	if len(cookies) != 2 || cookies[0] != `note=a\b;theme="dark"` || cookies[1] != "session=abc" {
+		t.Fatalf("invalid cookies %q", cookies)
+	}
+
+	removeCookie(req, "missing")
+	if len(req.Header.Values("Cookie")) != 2 {
+		t.Fatalf("cookies must be kept when the cookie is missing")
+	}
+}
@@ -0,0 +1,112 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ChainHeader header with the country codes of the X-Forwarded-For hops, such as "DE,NL,US".
+	ChainHeader = "GeoIP-Chain"
+	// ChainMismatchHeader header set to "true" when the hops are in several countries.
+	ChainMismatchHeader = "GeoIP-Chain-Mismatch"
+
+	// defaultChainMaxHops default number of hops looked up.
+	defaultChainMaxHops = 5
+)
+
+// ChainConfig configures the lookup of every X-Forwarded-For hop.
+type ChainConfig struct {
+	// Enabled looks up the hops.
+	Enabled bool `json:"enabled,omitempty"`
+	// MaxHops bounds the number of hops looked up, from the client side of the chain.
+	MaxHops int `json:"maxHops,omitempty"`
+	// TrustedIPs are IPs and CIDRs of proxies that are skipped, like private and excluded IPs.
+	TrustedIPs []string `json:"trustedIPs,omitempty"` //nolint:tagliatelle
+}
+
+// chainLookup looks up the hops of X-Forwarded-For.
+type chainLookup struct {
+	maxHops int
+	trusted []*net.IPNet
+}
+
+// newChainLookup validates the config and creates the chain lookup. Returns nil when it is disabled.
+func newChainLookup(cfg ChainConfig) (*chainLookup, error) {
+	if !cfg.Enabled {
+		return nil, nil //nolint:nilnil
+	}
+	if cfg.MaxHops < 0 {
+		return nil, fmt.Errorf("invalid chain max hops: maxHops=%d", cfg.MaxHops)
+	}
+
+	trusted, err := parseCIDRs(cfg.TrustedIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &chainLookup{maxHops: cfg.MaxHops, trusted: trusted}
+	if c.maxHops == 0 {
+		c.maxHops = defaultChainMaxHops
+	}
+	return c, nil
+}
+
+// skippedHop checks if a hop is not looked up: private, loopback, link-local, trusted or excluded.
+func (mw *TraefikGeoIP) skippedHop(ip net.IP) bool {
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || mw.isExcluded(ip) {
+		return true
+	}
+	for _, cidr := range mw.chain.trusted {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// setChainHeaders looks up the hops of X-Forwarded-For, and sets their country codes.
+// Hops that can not be parsed or looked up count as unknown.
+func (mw *TraefikGeoIP) setChainHeaders(req *http.Request) {
+	if mw.chain == nil {
+		return
+	}
+
+	codes := []string{}
+	countries := map[string]bool{}
+	for _, xff := range req.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(xff, ",") {
+			if len(codes) >= mw.chain.maxHops {
+				break
+			}
+
+			ip := net.ParseIP(strings.TrimSpace(hop))
+			if ip != nil && mw.skippedHop(ip) {
+				continue
+			}
+
+			code := Unknown
+			if ip != nil {
+				if result, err := mw.lookup(ip); err == nil {
+					code = result.countryCode
+				} else if mw.debug {
+					log.Printf("[geoip] chain lookup error: ip=%v, name=%s, err=%v", ip, mw.name, err)
+				}
+			}
+			if code != Unknown {
+				countries[code] = true
+			}
+			codes = append(codes, code)
+		}
+	}
+
+	if len(codes) == 0 {
+		return
+	}
+	req.Header.Set(ChainHeader, strings.Join(codes, ","))
+	req.Header.Set(ChainMismatchHeader, strconv.FormatBool(len(countries) > 1))
+}
@@ -0,0 +1,59 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChainHeaders(t *testing.T) {
+	countries := map[string]string{
+		"188.193.88.199": "DE",
+		"145.1.1.1":      "NL",
+		"3.3.3.3":        "US",
+		"4.4.4.4":        "US",
+	}
+	lookup := func(ip net.IP) (*GeoIPResult, error) {
+		result := munichResult()
+		result.countryCode = countries[ip.String()]
+		if result.countryCode == "" {
+			result.countryCode = Unknown
+		}
+		return result, nil
+	}
+
+	cfg := CreateConfig()
+	cfg.Chain = ChainConfig{Enabled: true, MaxHops: 4, TrustedIPs: []string{"4.4.4.0/24"}}
+	cfg.ExcludeIPs = []string{"5.5.5.5"}
+	instance, err := newMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}), cfg, "traefik_geoip", lookup)
+	if err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+
+	tests := []struct {
+		xff      []string
+		chain    string
+		mismatch string
+	}{
+		{[]string{"188.193.88.199, 10.0.0.1, 145.1.1.1", "4.4.4.4, 3.3.3.3"}, "DE,NL,US", "true"},
+		{[]string{"188.193.88.199, 5.5.5.5, 127.0.0.1, 188.193.88.199"}, "DE,DE", "false"},
+		{[]string{"188.193.88.199, 9.9.9.9, garbage, 3.3.3.3, 145.1.1.1"}, "DE,XX,XX,US", "true"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		req.RemoteAddr = "10.0.0.2:9999"
+		for _, xff := range test.xff {
+			req.Header.Add("X-Forwarded-For", xff)
+		}
+		instance.ServeHTTP(httptest.NewRecorder(), req)
+		if req.Header.Get(ChainHeader) != test.chain || req.Header.Get(ChainMismatchHeader) != test.mismatch {
+			t.Fatalf("invalid chain for %v: %s, mismatch %s", test.xff, req.Header.Get(ChainHeader), req.Header.Get(ChainMismatchHeader))
+		}
+	}
+
+	cfg.Chain.MaxHops = -1
+	if _, err := newMiddleware(nil, cfg, "traefik_geoip", lookup); err == nil {
+		t.Fatalf("Must fail on invalid max hops")
+	}
+}
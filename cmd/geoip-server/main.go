@@ -0,0 +1,215 @@
+// Command geoip-server runs the traefik_geoip middleware as a standalone HTTP service, for proxies that can not
+// load the plugin. It answers Traefik ForwardAuth and nginx auth_request subrequests.
+//
+// The client IP, method, host and URI of the original request are read from the forwarded headers.
+// Allowed requests are answered with 200 and the geo headers, so the proxy can copy them to the upstream request.
+// Blocked requests are answered like the middleware would, such as with 403 or a redirect.
+// The self-lookup and health endpoints are turned off, as every path is a forwarded one.
+//
+//	geoip-server -config geoip.yml -listen :8080
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	traefik_geoip "github.com/Maronato/traefik_geoip" //nolint:revive,stylecheck
+)
+
+// shutdownTimeout longest wait for in-flight subrequests on shutdown.
+const shutdownTimeout = 10 * time.Second
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+// run runs the server and returns its exit code.
+func run(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("geoip-server", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configPath := flags.String("config", "", "YAML or JSON config of the middleware")
+	listen := flags.String("listen", ":8080", "address to listen on")
+	name := flags.String("name", "geoip-server", "name of the middleware in logs and audit entries")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "unable to load config: %v\n", err)
+		return 1
+	}
+
+	// Stop on SIGINT and SIGTERM, letting in-flight subrequests finish.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	handler, err := newHandler(ctx, cfg, *name)
+	if err != nil {
+		fmt.Fprintf(stderr, "unable to create middleware: %v\n", err)
+		return 1
+	}
+
+	server := &http.Server{
+		Addr:              *listen,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	served := make(chan error, 1)
+	go func() {
+		log.Printf("[geoip] listening: addr=%s, name=%s", *listen, *name)
+		served <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-served:
+		fmt.Fprintf(stderr, "unable to serve: %v\n", err)
+		return 1
+	case <-ctx.Done():
+	}
+
+	log.Printf("[geoip] shutting down: name=%s", *name)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		fmt.Fprintf(stderr, "unable to shut down: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// loadConfig reads the middleware's config from a YAML or JSON file, or returns the default one.
+// The file holds the options of the middleware, as in Traefik's plugin configuration.
+func loadConfig(path string) (*traefik_geoip.Config, error) {
+	cfg := traefik_geoip.CreateConfig()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		value, err := parseYAMLAs(string(data), reflect.TypeOf(cfg))
+		if err != nil {
+			return nil, err
+		}
+		// The YAML is turned into JSON to decode it with the same schema.
+		if data, err = json.Marshal(value); err != nil {
+			return nil, err
+		}
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// newHandler creates the middleware, answering allowed subrequests with their geo headers.
+// The middleware stops loading a missing database in the background when ctx is done.
+func newHandler(ctx context.Context, cfg *traefik_geoip.Config, name string) (http.Handler, error) {
+	// The endpoints would answer forwarded paths with a 200, which proxies take as allowed without any rule
+	// being evaluated, so they are turned off.
+	serverCfg := *cfg
+	if serverCfg.Whoami.Path != "" || serverCfg.HealthPath != "" {
+		log.Printf("[geoip] whoami and health endpoints are not available in the standalone server: name=%s", name)
+		serverCfg.Whoami.Path = ""
+		serverCfg.HealthPath = ""
+	}
+
+	middleware, err := traefik_geoip.New(ctx, http.HandlerFunc(allow), &serverCfg, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		req = forwardedRequest(req)
+		// Remember the original headers, to answer with the ones set by the middleware.
+		ctx := context.WithValue(req.Context(), originalHeaderKey{}, req.Header.Clone())
+		middleware.ServeHTTP(rw, req.WithContext(ctx))
+	}), nil
+}
+
+// originalHeaderKey the context key of the subrequest's headers before the middleware.
+type originalHeaderKey struct{}
+
+// allow answers allowed subrequests with 200, and the headers the middleware added or changed.
+func allow(rw http.ResponseWriter, req *http.Request) {
+	original, _ := req.Context().Value(originalHeaderKey{}).(http.Header)
+	for name, values := range req.Header {
+		if !equalValues(original[name], values) {
+			rw.Header()[name] = values
+		}
+	}
+	rw.WriteHeader(http.StatusOK)
+}
+
+// equalValues checks if two header values are the same.
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// forwardedRequest rebuilds the original request from the headers of a subrequest.
+// Traefik ForwardAuth sets X-Forwarded-Method, X-Forwarded-Host and X-Forwarded-Uri, and nginx is usually
+// configured to set X-Original-Method and X-Original-URI. The client IP is read from X-Forwarded-For,
+// or X-Real-Ip when it is missing.
+func forwardedRequest(req *http.Request) *http.Request {
+	req = req.Clone(req.Context())
+
+	if method := firstHeader(req, "X-Forwarded-Method", "X-Original-Method"); method != "" {
+		req.Method = method
+	}
+	if host := firstHeader(req, "X-Forwarded-Host"); host != "" {
+		req.Host = host
+	}
+	if uri := firstHeader(req, "X-Forwarded-Uri", "X-Original-URI"); uri != "" {
+		path, query, _ := strings.Cut(uri, "?")
+		req.URL.Path = path
+		req.URL.RawPath = ""
+		req.URL.RawQuery = query
+		req.RequestURI = uri
+	}
+	if req.Header.Get("X-Forwarded-For") == "" {
+		if realIP := req.Header.Get("X-Real-Ip"); realIP != "" {
+			req.Header.Set("X-Forwarded-For", realIP)
+		}
+	}
+
+	return req
+}
+
+// firstHeader returns the first of the headers that is set.
+func firstHeader(req *http.Request, names ...string) string {
+	for _, name := range names {
+		if value := req.Header.Get(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
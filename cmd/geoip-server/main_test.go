@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	traefik_geoip "github.com/Maronato/traefik_geoip" //nolint:revive,stylecheck
+)
+
+func TestForwardAuth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geoip.yml")
+	config := `
+dbPath: missing.mmdb
+onMissingDatabase: passthrough
+setRealIP: true
+healthPath: /health
+whoami:
+  path: /whoami
+rules:
+  - name: deny-admin
+    hosts: [example.com]
+    pathPrefixes: [/admin]
+    action: deny
+  - name: tag-api
+    pathPrefixes: [/api]
+    action: tag
+    header: X-Api
+    value: "1"
+`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatalf("Error writing %v", err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("Error loading config %v", err)
+	}
+	if cfg.OnMissingDatabase != traefik_geoip.MissingDatabasePassthrough || len(cfg.Rules) != 2 || cfg.GeohashPrecision != 12 {
+		t.Fatalf("invalid config %+v", cfg)
+	}
+
+	handler, err := newHandler(context.Background(), cfg, "test")
+	if err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+
+	serve := func(host, uri string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "http://geoip-server/auth", nil)
+		req.Header.Set("X-Forwarded-Method", http.MethodPost)
+		req.Header.Set("X-Forwarded-Host", host)
+		req.Header.Set("X-Forwarded-Uri", uri)
+		req.Header.Set("X-Real-Ip", "188.193.88.199")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	if recorder := serve("example.com", "/admin/users?page=2"); recorder.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", recorder.Code)
+	}
+	if recorder := serve("other.example.com", "/admin"); recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", recorder.Code)
+	}
+
+	recorder := serve("example.com", "/api/items")
+	if recorder.Code != http.StatusOK || recorder.Header().Get("X-Api") != "1" {
+		t.Fatalf("allowed requests must be answered with the headers set by the middleware, got %d %v", recorder.Code, recorder.Header())
+	}
+	if recorder.Header().Get("X-Forwarded-Host") != "" {
+		t.Fatalf("unchanged headers must not be answered")
+	}
+
+	// The endpoints never answer forwarded paths, rules still apply to them.
+	for _, uri := range []string{"/health", "/whoami"} {
+		if recorder := serve("example.com", uri); recorder.Header().Get("Content-Type") == "application/json" {
+			t.Fatalf("%s must not be answered by the endpoint", uri)
+		}
+	}
+	cfg.Rules[0].PathPrefixes = []string{"/whoami"}
+	if handler, err = newHandler(context.Background(), cfg, "test"); err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+	if recorder := serve("example.com", "/whoami"); recorder.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", recorder.Code)
+	}
+}
+
+func TestLoadConfigScalars(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geoip.yaml")
+	config := `
+geohashPrecision: 5
+debug: true
+rules:
+  - name: deny-asn
+    asns: [12345, AS64496]
+    statusCode: 451
+    action: deny
+  - name: tag-version
+    action: tag
+    header: X-Version
+    value: 1.50
+  - name: tag-flag
+    action: tag
+    header: X-Flag
+    value: true
+`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatalf("Error writing %v", err)
+	}
+
+	// Plain scalars that read as numbers or bools are taken as written by string fields.
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("Error loading %v", err)
+	}
+	if cfg.GeohashPrecision != 5 || !cfg.Debug || len(cfg.Rules) != 3 {
+		t.Fatalf("invalid config %+v", cfg)
+	}
+	if asns := cfg.Rules[0].ASNs; len(asns) != 2 || asns[0] != "12345" || asns[1] != "AS64496" || cfg.Rules[0].StatusCode != 451 {
+		t.Fatalf("invalid rule %+v", cfg.Rules[0])
+	}
+	if cfg.Rules[1].Value != "1.50" || cfg.Rules[2].Value != "true" {
+		t.Fatalf("invalid tag values %q, %q", cfg.Rules[1].Value, cfg.Rules[2].Value)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"unknown.json": `{"dbPath": "a.mmdb", "unknownOption": true}`,
+		"invalid.yml":  "dbPath: a.mmdb\n  nested: b",
+		"types.yaml":   "geohashPrecision: high",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Error writing %v", err)
+		}
+		if _, err := loadConfig(path); err == nil {
+			t.Fatalf("Must fail on %s", name)
+		}
+	}
+}
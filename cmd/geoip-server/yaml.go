@@ -0,0 +1,390 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// yamlLine a meaningful line of a YAML document.
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+// yamlScalar a plain scalar that reads as a bool or a number.
+// Its text is kept, as string fields take it as is, such as "asns: [12345]".
+type yamlScalar struct {
+	text  string
+	value interface{}
+}
+
+// parseYAML parses the subset of YAML needed by the middleware's config into values that encode to JSON:
+// block maps and lists, flow lists and maps of scalars, quoted and plain scalars, and comments.
+// Anchors, tags, multi-line strings and multiple documents are not supported.
+func parseYAML(data string) (interface{}, error) {
+	return parseYAMLAs(data, nil)
+}
+
+// parseYAMLAs parses the document like parseYAML, for a value of type target.
+// Plain scalars that read as bools or numbers stay strings where target has a string.
+func parseYAMLAs(data string, target reflect.Type) (interface{}, error) {
+	lines := []yamlLine{}
+	for i, raw := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		text := strings.TrimRight(stripYAMLComment(raw), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		lines = append(lines, yamlLine{number: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	p := &yamlParser{lines: lines}
+	value, err := p.parseBlock(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].number)
+	}
+	return resolveYAML(value, target), nil
+}
+
+// resolveYAML replaces the plain scalars in value with their text where target has a string,
+// and with their bool or number elsewhere. A nil target has no strings.
+func resolveYAML(value interface{}, target reflect.Type) interface{} {
+	for target != nil && target.Kind() == reflect.Ptr {
+		target = target.Elem()
+	}
+
+	switch v := value.(type) {
+	case yamlScalar:
+		if target != nil && target.Kind() == reflect.String {
+			return v.text
+		}
+		return v.value
+	case []interface{}:
+		var elem reflect.Type
+		if target != nil && (target.Kind() == reflect.Slice || target.Kind() == reflect.Array) {
+			elem = target.Elem()
+		}
+		for i, item := range v {
+			v[i] = resolveYAML(item, elem)
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = resolveYAML(item, yamlFieldType(target, key))
+		}
+	}
+	return value
+}
+
+// yamlFieldType returns the type of the key's value in target, matched like encoding/json matches fields.
+func yamlFieldType(target reflect.Type, key string) reflect.Type {
+	if target == nil {
+		return nil
+	}
+	switch target.Kind() {
+	case reflect.Map:
+		return target.Elem()
+	case reflect.Struct:
+		var folded reflect.Type
+		for i := 0; i < target.NumField(); i++ {
+			field := target.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" || field.PkgPath != "" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if name == key {
+				return field.Type
+			}
+			if folded == nil && strings.EqualFold(name, key) {
+				folded = field.Type
+			}
+		}
+		return folded
+	}
+	return nil
+}
+
+// stripYAMLComment removes a comment, unless the # is quoted or inside a word.
+func stripYAMLComment(line string) string {
+	quote := rune(0)
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// yamlParser parses the lines of a document.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// isListItem checks if the text is a block list item.
+func isListItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseBlock parses the map or list at the indent.
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	if isListItem(p.lines[p.pos].text) {
+		return p.parseList(indent)
+	}
+	return p.parseMap(indent)
+}
+
+// parseList parses the list items at the indent.
+func (p *yamlParser) parseList(indent int) (interface{}, error) {
+	items := []interface{}{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isListItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+
+		switch {
+		case rest == "":
+			// The item is the following, more indented, block.
+			p.pos++
+			if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+				items = append(items, nil)
+				continue
+			}
+			item, err := p.parseBlock(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		case isMapEntry(rest):
+			// The item is a map starting on the same line, with its keys aligned after the dash.
+			p.lines[p.pos] = yamlLine{number: line.number, indent: line.indent + len(line.text) - len(rest), text: rest}
+			item, err := p.parseMap(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		default:
+			item, err := parseYAMLValue(rest, line.number)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			p.pos++
+		}
+	}
+	return items, nil
+}
+
+// parseMap parses the map entries at the indent.
+func (p *yamlParser) parseMap(indent int) (interface{}, error) {
+	values := map[string]interface{}{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		line := p.lines[p.pos]
+		if isListItem(line.text) {
+			return nil, fmt.Errorf("line %d: unexpected list item", line.number)
+		}
+
+		key, rest, err := splitMapEntry(line.text, line.number)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := values[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key %s", line.number, key)
+		}
+		p.pos++
+
+		if rest != "" {
+			if values[key], err = parseYAMLValue(rest, line.number); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// The value is the following block, more indented, or a list at the same indent.
+		switch {
+		case p.pos < len(p.lines) && p.lines[p.pos].indent > indent:
+			values[key], err = p.parseBlock(p.lines[p.pos].indent)
+		case p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isListItem(p.lines[p.pos].text):
+			values[key], err = p.parseList(indent)
+		default:
+			values[key] = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// isMapEntry checks if the text is a "key: value" entry.
+func isMapEntry(text string) bool {
+	_, _, err := splitMapEntry(text, 0)
+	return err == nil
+}
+
+// splitMapEntry splits a "key: value" entry. The key may be quoted.
+func splitMapEntry(text string, number int) (string, string, error) {
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end < 0 || (end+1 < len(text) && text[end+1] != ':') || end+1 >= len(text) {
+			return "", "", fmt.Errorf("line %d: invalid key", number)
+		}
+		key, err := parseYAMLValue(text[:end+1], number)
+		if err != nil {
+			return "", "", err
+		}
+		return key.(string), strings.TrimSpace(text[end+2:]), nil
+	}
+	if text[0] == '[' || text[0] == '{' {
+		return "", "", fmt.Errorf("line %d: expected a key", number)
+	}
+
+	if strings.HasSuffix(text, ":") {
+		return text[:len(text)-1], "", nil
+	}
+	index := strings.Index(text, ": ")
+	if index <= 0 {
+		return "", "", fmt.Errorf("line %d: expected a key", number)
+	}
+	return text[:index], strings.TrimSpace(text[index+2:]), nil
+}
+
+// closingQuote returns the index of the quote closing the string starting the text, or -1.
+func closingQuote(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// parseYAMLValue parses a scalar or a flow list or map.
+func parseYAMLValue(text string, number int) (interface{}, error) {
+	switch text[0] {
+	case '|', '>':
+		return nil, fmt.Errorf("line %d: multi-line strings are not supported", number)
+	case '&', '*', '!':
+		return nil, fmt.Errorf("line %d: anchors, aliases and tags are not supported", number)
+	case '[':
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("line %d: unterminated flow list", number)
+		}
+		items := []interface{}{}
+		for _, part := range splitFlow(text[1 : len(text)-1]) {
+			item, err := parseYAMLValue(part, number)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case '{':
+		if !strings.HasSuffix(text, "}") {
+			return nil, fmt.Errorf("line %d: unterminated flow map", number)
+		}
+		values := map[string]interface{}{}
+		for _, part := range splitFlow(text[1 : len(text)-1]) {
+			key, rest, err := splitMapEntry(part, number)
+			if err != nil {
+				return nil, err
+			}
+			if rest == "" {
+				values[key] = nil
+				continue
+			}
+			if values[key], err = parseYAMLValue(rest, number); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	case '"':
+		if closingQuote(text) != len(text)-1 {
+			return nil, fmt.Errorf("line %d: invalid quoted string", number)
+		}
+		value, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid quoted string", number)
+		}
+		return value, nil
+	case '\'':
+		if closingQuote(text) != len(text)-1 {
+			return nil, fmt.Errorf("line %d: invalid quoted string", number)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	}
+
+	switch text {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return yamlScalar{text: text, value: true}, nil
+	case "false", "False", "FALSE":
+		return yamlScalar{text: text, value: false}, nil
+	}
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return yamlScalar{text: text, value: i}, nil
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return yamlScalar{text: text, value: f}, nil
+	}
+	return text, nil
+}
+
+// splitFlow splits the content of a flow list or map on the commas that are not quoted.
+func splitFlow(text string) []string {
+	parts := []string{}
+	quote := byte(0)
+	start := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			parts = append(parts, text[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, text[start:])
+
+	trimmed := []string{}
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			trimmed = append(trimmed, part)
+		}
+	}
+	return trimmed
+}
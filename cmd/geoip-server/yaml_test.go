@@ -0,0 +1,118 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	value, err := parseYAML(`
+# Geo config
+dbPath: /plugins/GeoLite2-City.mmdb
+debug: true
+geohashPrecision: 5
+excludeIPs:
+  - "10.0.0.0/8"
+  - 192.168.0.0/16 # private
+redirects:
+  DE: "https://de.example.com{path}"
+  '@EU': https://eu.example.com/#home
+rules:
+  - name: deny-ru
+    countries: [RU, "BY"]
+    action: deny
+  - name: status
+    headers: {X-Probe: "^yes$"}
+    pathPrefixes:
+    - /status
+regions:
+-
+  name: it's
+  latitude: 48.1
+empty:
+`)
+	if err != nil {
+		t.Fatalf("Error parsing %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"dbPath":           "/plugins/GeoLite2-City.mmdb",
+		"debug":            true,
+		"geohashPrecision": int64(5),
+		"excludeIPs":       []interface{}{"10.0.0.0/8", "192.168.0.0/16"},
+		"redirects": map[string]interface{}{
+			"DE":  "https://de.example.com{path}",
+			"@EU": "https://eu.example.com/#home",
+		},
+		"rules": []interface{}{
+			map[string]interface{}{"name": "deny-ru", "countries": []interface{}{"RU", "BY"}, "action": "deny"},
+			map[string]interface{}{
+				"name":         "status",
+				"headers":      map[string]interface{}{"X-Probe": "^yes$"},
+				"pathPrefixes": []interface{}{"/status"},
+			},
+		},
+		"regions": []interface{}{map[string]interface{}{"name": "it's", "latitude": 48.1}},
+		"empty":   nil,
+	}
+	if !reflect.DeepEqual(value, expected) {
+		t.Fatalf("invalid value %#v", value)
+	}
+}
+
+func TestParseYAMLAs(t *testing.T) {
+	type rule struct {
+		ASNs  []string `json:"asns"`
+		Value string   `json:"value"`
+		Code  int      `json:"code"`
+	}
+	type config struct {
+		Rules  []rule            `json:"rules"`
+		Labels map[string]string `json:"labels"`
+		Debug  bool              `json:"debug"`
+	}
+
+	value, err := parseYAMLAs(`
+debug: true
+labels: {build: 007}
+rules:
+  - asns: [12345, 1e3]
+    value: 1
+    code: 403
+    extra: 5
+`, reflect.TypeOf(&config{}))
+	if err != nil {
+		t.Fatalf("Error parsing %v", err)
+	}
+
+	// Unknown keys keep their type, for the decoder to reject them.
+	expected := map[string]interface{}{
+		"debug":  true,
+		"labels": map[string]interface{}{"build": "007"},
+		"rules": []interface{}{map[string]interface{}{
+			"asns":  []interface{}{"12345", "1e3"},
+			"value": "1",
+			"code":  int64(403),
+			"extra": int64(5),
+		}},
+	}
+	if !reflect.DeepEqual(value, expected) {
+		t.Fatalf("invalid value %#v", value)
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	for _, document := range []string{
+		"a: b\n  c: d",
+		"a: |\n  text",
+		"a: &anchor b",
+		"a: b\na: c",
+		"a: [b",
+		"a: \"b",
+		"- a\nb: c",
+	} {
+		if _, err := parseYAML(document); err == nil {
+			t.Fatalf("Must fail on %q", document)
+		}
+	}
+}
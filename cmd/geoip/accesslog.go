@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	traefik_geoip "github.com/Maronato/traefik_geoip" //nolint:revive,stylecheck
+)
+
+const (
+	// accessLogFieldPrefix prefixes the geo fields added to access log records.
+	accessLogFieldPrefix = "geoip_"
+	// maxAccessLogLine longest access log record read.
+	maxAccessLogLine = 1024 * 1024
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// accessLogRecord the fields of a Traefik JSON access log record used to find the client IP.
+type accessLogRecord struct {
+	ClientHost    string `json:"ClientHost"`
+	ForwardedFor  string `json:"request_X-Forwarded-For"` //nolint:tagliatelle
+	RequestHost   string `json:"RequestHost"`
+	RequestPath   string `json:"RequestPath"`
+	RequestMethod string `json:"RequestMethod"`
+}
+
+// resolveRequestFunc resolves the client of a request.
+type resolveRequestFunc func(req *http.Request) ([]traefik_geoip.Field, error)
+
+// runAccessLog enriches access logs with the geo fields of their clients, and returns the exit code.
+func runAccessLog(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("geoip accesslog", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	openResolver := resolverFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	resolver, err := openResolver()
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+	resolve := func(req *http.Request) ([]traefik_geoip.Field, error) {
+		result, err := resolver.Resolve(req)
+		if err != nil {
+			return nil, err
+		}
+		return result.Fields(), nil
+	}
+
+	inputs := flags.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+
+	out := bufio.NewWriter(stdout)
+	enriched, unresolved := 0, 0
+	for _, input := range inputs {
+		in, closeInput, err := openAccessLog(input, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "unable to open %s: %v\n", input, err)
+			return 1
+		}
+
+		e, u, err := enrichAccessLog(in, input, resolve, out, stderr)
+		closeInput()
+		enriched += e
+		unresolved += u
+		if err != nil {
+			fmt.Fprintf(stderr, "unable to enrich %s: %v\n", input, err)
+			return 1
+		}
+	}
+	if err := out.Flush(); err != nil {
+		fmt.Fprintf(stderr, "unable to write output: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(stderr, "enriched %d records, %d unresolvable\n", enriched, unresolved)
+	return 0
+}
+
+// openAccessLog opens an access log, "-" being stdin. Gzip compressed logs, such as rotated ones, are decompressed.
+func openAccessLog(name string, stdin io.Reader) (io.Reader, func(), error) {
+	var in io.Reader = stdin
+	closeInput := func() {}
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		in, closeInput = file, func() { _ = file.Close() }
+	}
+
+	buffered := bufio.NewReader(in)
+	magic, err := buffered.Peek(len(gzipMagic))
+	if err != nil || !bytes.Equal(magic, gzipMagic) {
+		// Empty and short inputs are not compressed.
+		return buffered, closeInput, nil
+	}
+
+	gz, err := gzip.NewReader(buffered)
+	if err != nil {
+		closeInput()
+		return nil, nil, err
+	}
+	return gz, func() { _ = gz.Close(); closeInput() }, nil
+}
+
+// enrichAccessLog appends the geo fields of the client to every JSON record, keeping the record as is otherwise.
+// Records whose client can not be resolved, and lines longer than maxAccessLogLine, are written unchanged,
+// and reported with their line number.
+// Returns the number of enriched and unresolvable records.
+func enrichAccessLog(in io.Reader, name string, resolve resolveRequestFunc, out io.Writer, report io.Writer) (int, int, error) {
+	reader := bufio.NewReaderSize(in, maxAccessLogLine)
+
+	enriched, unresolved := 0, 0
+	for number := 1; ; number++ {
+		chunk, readErr := reader.ReadSlice('\n')
+		if errors.Is(readErr, bufio.ErrBufferFull) {
+			unresolved++
+			fmt.Fprintf(report, "%s:%d: line longer than %d bytes\n", name, number, maxAccessLogLine)
+			if err := copyLine(reader, chunk, out); err != nil {
+				return enriched, unresolved, err
+			}
+			continue
+		}
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return enriched, unresolved, readErr
+		}
+
+		if line := bytes.TrimSpace(chunk); len(line) > 0 {
+			enrichedLine, err := enrichRecord(line, resolve)
+			if err != nil {
+				unresolved++
+				fmt.Fprintf(report, "%s:%d: %v\n", name, number, err)
+				enrichedLine = line
+			} else {
+				enriched++
+			}
+
+			if _, err := out.Write(enrichedLine); err != nil {
+				return enriched, unresolved, err
+			}
+			if _, err := out.Write([]byte("\n")); err != nil {
+				return enriched, unresolved, err
+			}
+		}
+
+		if errors.Is(readErr, io.EOF) {
+			return enriched, unresolved, nil
+		}
+	}
+}
+
+// copyLine writes the rest of a line that does not fit in the reader's buffer, starting with the chunk already read.
+func copyLine(reader *bufio.Reader, chunk []byte, out io.Writer) error {
+	for {
+		if _, err := out.Write(chunk); err != nil {
+			return err
+		}
+
+		var err error
+		chunk, err = reader.ReadSlice('\n')
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+		case errors.Is(err, io.EOF):
+			if _, err := out.Write(chunk); err != nil {
+				return err
+			}
+			_, err := out.Write([]byte("\n"))
+			return err
+		case err != nil:
+			return err
+		default:
+			_, err := out.Write(chunk)
+			return err
+		}
+	}
+}
+
+// enrichRecord resolves the client of a record, and appends its geo fields.
+func enrichRecord(line []byte, resolve resolveRequestFunc) ([]byte, error) {
+	record := accessLogRecord{}
+	if err := json.Unmarshal(line, &record); err != nil || line[len(line)-1] != '}' {
+		return nil, errors.New("not a JSON record")
+	}
+	if record.ClientHost == "" && record.ForwardedFor == "" {
+		return nil, errors.New("no ClientHost or request_X-Forwarded-For")
+	}
+
+	// Rebuild the request, so the client IP is found exactly like the middleware does.
+	req := &http.Request{Method: record.RequestMethod, Host: record.RequestHost, RemoteAddr: record.ClientHost, Header: http.Header{}}
+	if record.ForwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", record.ForwardedFor)
+	}
+
+	fields, err := resolve(req)
+	if err != nil {
+		return nil, err
+	}
+
+	enriched := append([]byte{}, line[:len(line)-1]...)
+	separator := []byte(",")
+	if bytes.HasSuffix(bytes.TrimSpace(enriched), []byte("{")) {
+		separator = nil
+	}
+	for _, field := range fields {
+		key, _ := json.Marshal(accessLogFieldPrefix + field.Name)
+		value, _ := json.Marshal(field.Value)
+		enriched = append(enriched, separator...)
+		enriched = append(enriched, key...)
+		enriched = append(enriched, ':')
+		enriched = append(enriched, value...)
+		separator = []byte(",")
+	}
+	return append(enriched, '}'), nil
+}
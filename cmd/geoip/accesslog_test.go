@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	traefik_geoip "github.com/Maronato/traefik_geoip" //nolint:revive,stylecheck
+)
+
+// fakeResolveRequest resolves the first forwarded hop, or the remote address, like fakeResolve.
+func fakeResolveRequest(req *http.Request) ([]traefik_geoip.Field, error) {
+	ip := req.RemoteAddr
+	if forwarded := req.Header.Get("X-Forwarded-For"); forwarded != "" {
+		ip = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	if !strings.HasSuffix(ip, ".1") {
+		return nil, errors.New("not found")
+	}
+	return fakeResolve(ip).fields, nil
+}
+
+func TestEnrichAccessLog(t *testing.T) {
+	in := strings.Join([]string{
+		`{"ClientHost":"10.0.0.1","RequestPath":"/"}`,
+		`{"ClientHost":"192.168.1.2","request_X-Forwarded-For":"10.0.0.1, 192.168.1.2"}`,
+		`{"ClientHost":"10.0.0.2"}`,
+		`not json`,
+		``,
+		`{"RequestPath":"/"}`,
+	}, "\n")
+
+	out, report := bytes.Buffer{}, bytes.Buffer{}
+	enriched, unresolved, err := enrichAccessLog(strings.NewReader(in), "access.log", fakeResolveRequest, &out, &report)
+	if err != nil || enriched != 2 || unresolved != 3 {
+		t.Fatalf("expected 2 enriched and 3 unresolvable records, got %d, %d, %v", enriched, unresolved, err)
+	}
+
+	expected := `{"ClientHost":"10.0.0.1","RequestPath":"/","geoip_country":"Germany","geoip_countryCode":"DE"}
+{"ClientHost":"192.168.1.2","request_X-Forwarded-For":"10.0.0.1, 192.168.1.2","geoip_country":"Germany","geoip_countryCode":"DE"}
+{"ClientHost":"10.0.0.2"}
+not json
+{"RequestPath":"/"}
+`
+	if out.String() != expected {
+		t.Fatalf("invalid output:\n%s", out.String())
+	}
+
+	expectedReport := `access.log:3: not found
+access.log:4: not a JSON record
+access.log:6: no ClientHost or request_X-Forwarded-For
+`
+	if report.String() != expectedReport {
+		t.Fatalf("invalid report:\n%s", report.String())
+	}
+}
+
+func TestEnrichAccessLogLongLine(t *testing.T) {
+	long := `{"ClientHost":"10.0.0.1","RequestPath":"/` + strings.Repeat("a", maxAccessLogLine) + `"}`
+	in := strings.Join([]string{
+		`{"ClientHost":"10.0.0.1"}`,
+		long,
+		`{"ClientHost":"10.0.0.1"}`,
+	}, "\n")
+
+	// Lines too long to be read are passed through, and the following records are still enriched.
+	out, report := bytes.Buffer{}, bytes.Buffer{}
+	enriched, unresolved, err := enrichAccessLog(strings.NewReader(in), "access.log", fakeResolveRequest, &out, &report)
+	if err != nil || enriched != 2 || unresolved != 1 {
+		t.Fatalf("expected 2 enriched and 1 unresolvable records, got %d, %d, %v", enriched, unresolved, err)
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) != 4 || lines[1] != long || !strings.Contains(lines[2], "geoip_countryCode") || lines[3] != "" {
+		t.Fatalf("invalid output of %d lines", len(lines))
+	}
+	if report.String() != "access.log:2: line longer than 1048576 bytes\n" {
+		t.Fatalf("invalid report:\n%s", report.String())
+	}
+
+	// A long last line without a newline still ends with one.
+	out.Reset()
+	if _, unresolved, err := enrichAccessLog(strings.NewReader(long), "access.log", fakeResolveRequest, &out, &bytes.Buffer{}); err != nil ||
+		unresolved != 1 || out.String() != long+"\n" {
+		t.Fatalf("invalid long last line, %d, %v", unresolved, err)
+	}
+}
+
+func TestOpenAccessLogGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log.1.gz")
+	compressed := bytes.Buffer{}
+	gz := gzip.NewWriter(&compressed)
+	_, _ = gz.Write([]byte(`{"ClientHost":"10.0.0.1"}` + "\n"))
+	_ = gz.Close()
+	if err := os.WriteFile(path, compressed.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	in, closeInput, err := openAccessLog(path, nil)
+	if err != nil {
+		t.Fatalf("Error opening log %v", err)
+	}
+	defer closeInput()
+
+	out := bytes.Buffer{}
+	if enriched, _, err := enrichAccessLog(in, path, fakeResolveRequest, &out, &bytes.Buffer{}); err != nil || enriched != 1 {
+		t.Fatalf("expected 1 enriched record, got %d, %v", enriched, err)
+	}
+	if !strings.Contains(out.String(), `"geoip_countryCode":"DE"`) {
+		t.Fatalf("invalid output:\n%s", out.String())
+	}
+}
+
+func TestRunAccessLogMissingFile(t *testing.T) {
+	stderr := bytes.Buffer{}
+	code := run([]string{"accesslog", "-db", "missing.mmdb"}, strings.NewReader(""), &bytes.Buffer{}, &stderr)
+	if code != 1 || !strings.Contains(stderr.String(), "unable to open database") {
+		t.Fatalf("expected a database error, got %d: %s", code, stderr.String())
+	}
+}
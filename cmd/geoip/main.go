@@ -0,0 +1,323 @@
+// Command geoip looks up IPs with the same databases and code as the traefik_geoip middleware.
+//
+// IPs are given as arguments, or read from stdin one per line:
+//
+//	geoip -db GeoLite2-City.mmdb 81.2.69.160 2001:db8::1
+//	geoip -db GeoLite2-City.mmdb -format csv < ips.txt > ips.csv
+//
+// A JSON config of the middleware can be given with -config, to apply its geohash precision, geofences,
+// regions, locale and groups options.
+//
+// The accesslog subcommand enriches Traefik JSON access logs:
+//
+//	geoip accesslog -db GeoLite2-City.mmdb access.log access.log.1.gz > enriched.log
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	traefik_geoip "github.com/Maronato/traefik_geoip" //nolint:revive,stylecheck
+)
+
+const (
+	formatTable = "table"
+	formatCSV   = "csv"
+	formatJSONL = "jsonl"
+
+	// tableBatchRows rows aligned together in table output. The table is flushed after each batch,
+	// so large inputs are not held in memory, and columns are aligned per batch.
+	tableBatchRows = 100
+)
+
+// row the outcome of a single input.
+type row struct {
+	input  string
+	fields []traefik_geoip.Field
+	err    error
+}
+
+// resolveFunc resolves a single input.
+type resolveFunc func(input string) row
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command and returns its exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "accesslog" {
+		return runAccessLog(args[1:], stdin, stdout, stderr)
+	}
+
+	flags := flag.NewFlagSet("geoip", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	openResolver := resolverFlags(flags)
+	format := flags.String("format", formatTable, "output format: table, csv or jsonl")
+	workers := flags.Int("workers", runtime.NumCPU(), "number of concurrent lookups")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *format != formatTable && *format != formatCSV && *format != formatJSONL {
+		fmt.Fprintf(stderr, "invalid format: %s\n", *format)
+		return 2
+	}
+	if *workers < 1 {
+		*workers = 1
+	}
+
+	resolver, err := openResolver()
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+
+	inputs := make(chan string)
+	go func() {
+		defer close(inputs)
+		if flags.NArg() > 0 {
+			for _, arg := range flags.Args() {
+				inputs <- arg
+			}
+			return
+		}
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				inputs <- line
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			fmt.Fprintf(stderr, "unable to read input: %v\n", err)
+		}
+	}()
+
+	failed, err := process(inputs, *workers, lookup(resolver), *format, stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "unable to write output: %v\n", err)
+		return 1
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// resolverFlags registers the config and database flags, and returns a function opening the resolver they describe.
+func resolverFlags(flags *flag.FlagSet) func() (*traefik_geoip.Resolver, error) {
+	configPath := flags.String("config", "", "JSON config of the middleware")
+	dbPath := flags.String("db", "", "path of the City or Country database, overrides the config")
+	asnDBPath := flags.String("asn-db", "", "path of the ASN database, overrides the config")
+
+	return func() (*traefik_geoip.Resolver, error) {
+		cfg, err := loadConfig(*configPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load config: %w", err)
+		}
+		if *dbPath != "" {
+			cfg.DBPath = *dbPath
+		}
+		if *asnDBPath != "" {
+			cfg.ASNDBPath = *asnDBPath
+		}
+
+		resolver, err := traefik_geoip.NewResolver(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open database: %w", err)
+		}
+		return resolver, nil
+	}
+}
+
+// loadConfig reads the middleware's config, or returns the default one.
+func loadConfig(path string) (*traefik_geoip.Config, error) {
+	cfg := traefik_geoip.CreateConfig()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// lookup resolves inputs with the resolver.
+func lookup(resolver *traefik_geoip.Resolver) resolveFunc {
+	return func(input string) row {
+		ip := net.ParseIP(input)
+		if ip == nil {
+			return row{input: input, err: fmt.Errorf("invalid IP")}
+		}
+
+		result, err := resolver.Lookup(ip)
+		if err != nil {
+			return row{input: input, err: err}
+		}
+		return row{input: input, fields: result.Fields()}
+	}
+}
+
+// process resolves the inputs concurrently, and writes the rows in the order of the inputs.
+// Returns the number of inputs that could not be resolved.
+func process(inputs <-chan string, workers int, resolve resolveFunc, format string, out io.Writer) (int, error) {
+	type job struct {
+		input string
+		row   chan row
+	}
+
+	jobs := make(chan job)
+	// ordered holds the pending rows in input order. Its buffer bounds how far workers get ahead of the writer.
+	ordered := make(chan chan row, workers*4)
+
+	go func() {
+		defer close(jobs)
+		defer close(ordered)
+		for input := range inputs {
+			j := job{input: input, row: make(chan row, 1)}
+			ordered <- j.row
+			jobs <- j
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				j.row <- resolve(j.input)
+			}
+		}()
+	}
+	defer wg.Wait()
+
+	w := newWriter(format, out)
+	failed := 0
+	var writeErr error
+	for pending := range ordered {
+		r := <-pending
+		if r.err != nil {
+			failed++
+		}
+		if writeErr == nil {
+			writeErr = w.write(r)
+		}
+	}
+	if writeErr != nil {
+		return failed, writeErr
+	}
+	return failed, w.flush()
+}
+
+// writer writes rows in an output format.
+type writer struct {
+	format string
+	names  []string
+	out    io.Writer
+	csv    *csv.Writer
+	table  *tabwriter.Writer
+	header bool
+	rows   int
+}
+
+// newWriter creates a writer of the format.
+func newWriter(format string, out io.Writer) *writer {
+	w := &writer{format: format, names: traefik_geoip.FieldNames(), out: out}
+	switch format {
+	case formatCSV:
+		w.csv = csv.NewWriter(out)
+	case formatTable:
+		w.table = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	}
+	return w
+}
+
+// values returns the values of the row in the order of the names, with unknown values empty.
+func (w *writer) values(r row) []string {
+	byName := make(map[string]string, len(r.fields))
+	for _, field := range r.fields {
+		byName[field.Name] = field.Value
+	}
+
+	values := []string{r.input}
+	for _, name := range w.names {
+		values = append(values, byName[name])
+	}
+	errText := ""
+	if r.err != nil {
+		errText = r.err.Error()
+	}
+	return append(values, errText)
+}
+
+// write writes a row, after the header for table and CSV outputs.
+func (w *writer) write(r row) error {
+	columns := append(append([]string{"ip"}, w.names...), "error")
+
+	switch w.format {
+	case formatCSV:
+		if !w.header {
+			w.header = true
+			if err := w.csv.Write(columns); err != nil {
+				return err
+			}
+		}
+		return w.csv.Write(w.values(r))
+	case formatTable:
+		if !w.header {
+			w.header = true
+			if _, err := fmt.Fprintln(w.table, strings.Join(columns, "\t")); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w.table, strings.Join(w.values(r), "\t")); err != nil {
+			return err
+		}
+		if w.rows++; w.rows%tableBatchRows == 0 {
+			return w.table.Flush()
+		}
+		return nil
+	default:
+		values := map[string]string{"ip": r.input}
+		for _, field := range r.fields {
+			values[field.Name] = field.Value
+		}
+		if r.err != nil {
+			values["error"] = r.err.Error()
+		}
+		line, err := json.Marshal(values)
+		if err != nil {
+			return err
+		}
+		_, err = w.out.Write(append(line, '\n'))
+		return err
+	}
+}
+
+// flush flushes the buffered output.
+func (w *writer) flush() error {
+	switch w.format {
+	case formatCSV:
+		w.csv.Flush()
+		return w.csv.Error()
+	case formatTable:
+		return w.table.Flush()
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	traefik_geoip "github.com/Maronato/traefik_geoip" //nolint:revive,stylecheck
+)
+
+// fakeResolve resolves IPs ending with ".1" to Germany after a delay, so later inputs finish first.
+func fakeResolve(input string) row {
+	if !strings.HasSuffix(input, ".1") {
+		return row{input: input, err: errors.New("not found")}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return row{input: input, fields: []traefik_geoip.Field{
+		{Name: "country", Header: traefik_geoip.CountryHeader, Value: "Germany"},
+		{Name: "countryCode", Header: traefik_geoip.CountryCodeHeader, Value: "DE"},
+	}}
+}
+
+func inputsOf(values ...string) <-chan string {
+	inputs := make(chan string, len(values))
+	for _, value := range values {
+		inputs <- value
+	}
+	close(inputs)
+	return inputs
+}
+
+func TestProcessJSONL(t *testing.T) {
+	out := bytes.Buffer{}
+	failed, err := process(inputsOf("10.0.0.1", "10.0.0.2", "10.0.0.3"), 3, fakeResolve, formatJSONL, &out)
+	if err != nil || failed != 2 {
+		t.Fatalf("expected 2 failures, got %d, %v", failed, err)
+	}
+
+	expected := `{"country":"Germany","countryCode":"DE","ip":"10.0.0.1"}
+{"error":"not found","ip":"10.0.0.2"}
+{"error":"not found","ip":"10.0.0.3"}
+`
+	if out.String() != expected {
+		t.Fatalf("invalid output, rows must keep the input order:\n%s", out.String())
+	}
+}
+
+func TestProcessCSV(t *testing.T) {
+	out := bytes.Buffer{}
+	if _, err := process(inputsOf("10.0.0.1"), 1, fakeResolve, formatCSV, &out); err != nil {
+		t.Fatalf("Error processing %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ip,country,countryCode,region,") || !strings.HasSuffix(lines[0], ",error") ||
+		!strings.HasPrefix(lines[1], "10.0.0.1,Germany,DE,,") {
+		t.Fatalf("invalid CSV output:\n%s", out.String())
+	}
+}
+
+func TestTableFlushesBatches(t *testing.T) {
+	out := bytes.Buffer{}
+	w := newWriter(formatTable, &out)
+	for i := 0; i < tableBatchRows; i++ {
+		if err := w.write(fakeResolve("10.0.0.2")); err != nil {
+			t.Fatalf("Error writing %v", err)
+		}
+		if i < tableBatchRows-1 && out.Len() != 0 {
+			t.Fatalf("rows must be aligned per batch")
+		}
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != tableBatchRows+1 {
+		t.Fatalf("a full batch must be written, got %d lines", lines)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	stderr := bytes.Buffer{}
+	if code := run([]string{"-format", "xml"}, nil, &bytes.Buffer{}, &stderr); code != 2 {
+		t.Fatalf("invalid format must fail with 2, got %d", code)
+	}
+	if code := run([]string{"-db", "missing.mmdb", "81.2.69.160"}, nil, &bytes.Buffer{}, &stderr); code != 1 {
+		t.Fatalf("missing database must fail with 1, got %d", code)
+	}
+}
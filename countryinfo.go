@@ -0,0 +1,268 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+// countryInfo locale data of a country.
+type countryInfo struct {
+	// currency ISO 4217 code.
+	currency string
+	// languages ISO 639 codes, from the most to the least used.
+	languages []string
+	// callingCode international dialing prefix.
+	callingCode string
+}
+
+// countryInfoVersion date of the ISO 4217 and CLDR data the table was derived from.
+const countryInfoVersion = "2024-10-01"
+
+// countries locale data keyed by ISO 3166-1 alpha-2 country code.
+var countries = map[string]countryInfo{
+	"AD": {currency: "EUR", languages: []string{"ca"}, callingCode: "+376"},
+	"AE": {currency: "AED", languages: []string{"ar", "en"}, callingCode: "+971"},
+	"AF": {currency: "AFN", languages: []string{"fa", "ps", "uz", "tk"}, callingCode: "+93"},
+	"AG": {currency: "XCD", languages: []string{"en"}, callingCode: "+1268"},
+	"AI": {currency: "XCD", languages: []string{"en"}, callingCode: "+1264"},
+	"AL": {currency: "ALL", languages: []string{"sq"}, callingCode: "+355"},
+	"AM": {currency: "AMD", languages: []string{"hy"}, callingCode: "+374"},
+	"AO": {currency: "AOA", languages: []string{"pt"}, callingCode: "+244"},
+	"AQ": {currency: "", languages: nil, callingCode: "+672"},
+	"AR": {currency: "ARS", languages: []string{"es"}, callingCode: "+54"},
+	"AS": {currency: "USD", languages: []string{"en", "sm"}, callingCode: "+1684"},
+	"AT": {currency: "EUR", languages: []string{"de"}, callingCode: "+43"},
+	"AU": {currency: "AUD", languages: []string{"en"}, callingCode: "+61"},
+	"AW": {currency: "AWG", languages: []string{"nl", "pap"}, callingCode: "+297"},
+	"AX": {currency: "EUR", languages: []string{"sv"}, callingCode: "+358"},
+	"AZ": {currency: "AZN", languages: []string{"az"}, callingCode: "+994"},
+	"BA": {currency: "BAM", languages: []string{"bs", "hr", "sr"}, callingCode: "+387"},
+	"BB": {currency: "BBD", languages: []string{"en"}, callingCode: "+1246"},
+	"BD": {currency: "BDT", languages: []string{"bn"}, callingCode: "+880"},
+	"BE": {currency: "EUR", languages: []string{"nl", "fr", "de"}, callingCode: "+32"},
+	"BF": {currency: "XOF", languages: []string{"fr"}, callingCode: "+226"},
+	"BG": {currency: "BGN", languages: []string{"bg"}, callingCode: "+359"},
+	"BH": {currency: "BHD", languages: []string{"ar"}, callingCode: "+973"},
+	"BI": {currency: "BIF", languages: []string{"rn", "fr", "en"}, callingCode: "+257"},
+	"BJ": {currency: "XOF", languages: []string{"fr"}, callingCode: "+229"},
+	"BL": {currency: "EUR", languages: []string{"fr"}, callingCode: "+590"},
+	"BM": {currency: "BMD", languages: []string{"en"}, callingCode: "+1441"},
+	"BN": {currency: "BND", languages: []string{"ms"}, callingCode: "+673"},
+	"BO": {currency: "BOB", languages: []string{"es", "qu", "ay"}, callingCode: "+591"},
+	"BQ": {currency: "USD", languages: []string{"nl", "pap"}, callingCode: "+599"},
+	"BR": {currency: "BRL", languages: []string{"pt"}, callingCode: "+55"},
+	"BS": {currency: "BSD", languages: []string{"en"}, callingCode: "+1242"},
+	"BT": {currency: "BTN", languages: []string{"dz"}, callingCode: "+975"},
+	"BV": {currency: "NOK", languages: []string{"no"}, callingCode: "+47"},
+	"BW": {currency: "BWP", languages: []string{"en", "tn"}, callingCode: "+267"},
+	"BY": {currency: "BYN", languages: []string{"be", "ru"}, callingCode: "+375"},
+	"BZ": {currency: "BZD", languages: []string{"en", "es"}, callingCode: "+501"},
+	"CA": {currency: "CAD", languages: []string{"en", "fr"}, callingCode: "+1"},
+	"CC": {currency: "AUD", languages: []string{"en", "ms"}, callingCode: "+61"},
+	"CD": {currency: "CDF", languages: []string{"fr", "ln", "sw"}, callingCode: "+243"},
+	"CF": {currency: "XAF", languages: []string{"fr", "sg"}, callingCode: "+236"},
+	"CG": {currency: "XAF", languages: []string{"fr", "ln"}, callingCode: "+242"},
+	"CH": {currency: "CHF", languages: []string{"de", "fr", "it", "rm"}, callingCode: "+41"},
+	"CI": {currency: "XOF", languages: []string{"fr"}, callingCode: "+225"},
+	"CK": {currency: "NZD", languages: []string{"en"}, callingCode: "+682"},
+	"CL": {currency: "CLP", languages: []string{"es"}, callingCode: "+56"},
+	"CM": {currency: "XAF", languages: []string{"fr", "en"}, callingCode: "+237"},
+	"CN": {currency: "CNY", languages: []string{"zh"}, callingCode: "+86"},
+	"CO": {currency: "COP", languages: []string{"es"}, callingCode: "+57"},
+	"CR": {currency: "CRC", languages: []string{"es"}, callingCode: "+506"},
+	"CU": {currency: "CUP", languages: []string{"es"}, callingCode: "+53"},
+	"CV": {currency: "CVE", languages: []string{"pt"}, callingCode: "+238"},
+	"CW": {currency: "ANG", languages: []string{"nl", "pap"}, callingCode: "+599"},
+	"CX": {currency: "AUD", languages: []string{"en"}, callingCode: "+61"},
+	"CY": {currency: "EUR", languages: []string{"el", "tr"}, callingCode: "+357"},
+	"CZ": {currency: "CZK", languages: []string{"cs"}, callingCode: "+420"},
+	"DE": {currency: "EUR", languages: []string{"de"}, callingCode: "+49"},
+	"DJ": {currency: "DJF", languages: []string{"fr", "ar"}, callingCode: "+253"},
+	"DK": {currency: "DKK", languages: []string{"da"}, callingCode: "+45"},
+	"DM": {currency: "XCD", languages: []string{"en"}, callingCode: "+1767"},
+	"DO": {currency: "DOP", languages: []string{"es"}, callingCode: "+1809"},
+	"DZ": {currency: "DZD", languages: []string{"ar", "fr"}, callingCode: "+213"},
+	"EC": {currency: "USD", languages: []string{"es"}, callingCode: "+593"},
+	"EE": {currency: "EUR", languages: []string{"et"}, callingCode: "+372"},
+	"EG": {currency: "EGP", languages: []string{"ar"}, callingCode: "+20"},
+	"EH": {currency: "MAD", languages: []string{"ar"}, callingCode: "+212"},
+	"ER": {currency: "ERN", languages: []string{"ti", "ar", "en"}, callingCode: "+291"},
+	"ES": {currency: "EUR", languages: []string{"es", "ca", "gl", "eu"}, callingCode: "+34"},
+	"ET": {currency: "ETB", languages: []string{"am"}, callingCode: "+251"},
+	"FI": {currency: "EUR", languages: []string{"fi", "sv"}, callingCode: "+358"},
+	"FJ": {currency: "FJD", languages: []string{"en", "fj"}, callingCode: "+679"},
+	"FK": {currency: "FKP", languages: []string{"en"}, callingCode: "+500"},
+	"FM": {currency: "USD", languages: []string{"en"}, callingCode: "+691"},
+	"FO": {currency: "DKK", languages: []string{"fo"}, callingCode: "+298"},
+	"FR": {currency: "EUR", languages: []string{"fr"}, callingCode: "+33"},
+	"GA": {currency: "XAF", languages: []string{"fr"}, callingCode: "+241"},
+	"GB": {currency: "GBP", languages: []string{"en"}, callingCode: "+44"},
+	"GD": {currency: "XCD", languages: []string{"en"}, callingCode: "+1473"},
+	"GE": {currency: "GEL", languages: []string{"ka"}, callingCode: "+995"},
+	"GF": {currency: "EUR", languages: []string{"fr"}, callingCode: "+594"},
+	"GG": {currency: "GBP", languages: []string{"en"}, callingCode: "+44"},
+	"GH": {currency: "GHS", languages: []string{"en"}, callingCode: "+233"},
+	"GI": {currency: "GIP", languages: []string{"en"}, callingCode: "+350"},
+	"GL": {currency: "DKK", languages: []string{"kl", "da"}, callingCode: "+299"},
+	"GM": {currency: "GMD", languages: []string{"en"}, callingCode: "+220"},
+	"GN": {currency: "GNF", languages: []string{"fr"}, callingCode: "+224"},
+	"GP": {currency: "EUR", languages: []string{"fr"}, callingCode: "+590"},
+	"GQ": {currency: "XAF", languages: []string{"es", "fr", "pt"}, callingCode: "+240"},
+	"GR": {currency: "EUR", languages: []string{"el"}, callingCode: "+30"},
+	"GS": {currency: "GBP", languages: []string{"en"}, callingCode: "+500"},
+	"GT": {currency: "GTQ", languages: []string{"es"}, callingCode: "+502"},
+	"GU": {currency: "USD", languages: []string{"en", "ch"}, callingCode: "+1671"},
+	"GW": {currency: "XOF", languages: []string{"pt"}, callingCode: "+245"},
+	"GY": {currency: "GYD", languages: []string{"en"}, callingCode: "+592"},
+	"HK": {currency: "HKD", languages: []string{"zh", "en"}, callingCode: "+852"},
+	"HM": {currency: "AUD", languages: []string{"en"}, callingCode: "+672"},
+	"HN": {currency: "HNL", languages: []string{"es"}, callingCode: "+504"},
+	"HR": {currency: "EUR", languages: []string{"hr"}, callingCode: "+385"},
+	"HT": {currency: "HTG", languages: []string{"ht", "fr"}, callingCode: "+509"},
+	"HU": {currency: "HUF", languages: []string{"hu"}, callingCode: "+36"},
+	"ID": {currency: "IDR", languages: []string{"id"}, callingCode: "+62"},
+	"IE": {currency: "EUR", languages: []string{"en", "ga"}, callingCode: "+353"},
+	"IL": {currency: "ILS", languages: []string{"he", "ar"}, callingCode: "+972"},
+	"IM": {currency: "GBP", languages: []string{"en", "gv"}, callingCode: "+44"},
+	"IN": {currency: "INR", languages: []string{"hi", "en"}, callingCode: "+91"},
+	"IO": {currency: "USD", languages: []string{"en"}, callingCode: "+246"},
+	"IQ": {currency: "IQD", languages: []string{"ar", "ku"}, callingCode: "+964"},
+	"IR": {currency: "IRR", languages: []string{"fa"}, callingCode: "+98"},
+	"IS": {currency: "ISK", languages: []string{"is"}, callingCode: "+354"},
+	"IT": {currency: "EUR", languages: []string{"it"}, callingCode: "+39"},
+	"JE": {currency: "GBP", languages: []string{"en"}, callingCode: "+44"},
+	"JM": {currency: "JMD", languages: []string{"en"}, callingCode: "+1876"},
+	"JO": {currency: "JOD", languages: []string{"ar"}, callingCode: "+962"},
+	"JP": {currency: "JPY", languages: []string{"ja"}, callingCode: "+81"},
+	"KE": {currency: "KES", languages: []string{"sw", "en"}, callingCode: "+254"},
+	"KG": {currency: "KGS", languages: []string{"ky", "ru"}, callingCode: "+996"},
+	"KH": {currency: "KHR", languages: []string{"km"}, callingCode: "+855"},
+	"KI": {currency: "AUD", languages: []string{"en"}, callingCode: "+686"},
+	"KM": {currency: "KMF", languages: []string{"ar", "fr"}, callingCode: "+269"},
+	"KN": {currency: "XCD", languages: []string{"en"}, callingCode: "+1869"},
+	"KP": {currency: "KPW", languages: []string{"ko"}, callingCode: "+850"},
+	"KR": {currency: "KRW", languages: []string{"ko"}, callingCode: "+82"},
+	"KW": {currency: "KWD", languages: []string{"ar"}, callingCode: "+965"},
+	"KY": {currency: "KYD", languages: []string{"en"}, callingCode: "+1345"},
+	"KZ": {currency: "KZT", languages: []string{"kk", "ru"}, callingCode: "+7"},
+	"LA": {currency: "LAK", languages: []string{"lo"}, callingCode: "+856"},
+	"LB": {currency: "LBP", languages: []string{"ar", "fr"}, callingCode: "+961"},
+	"LC": {currency: "XCD", languages: []string{"en"}, callingCode: "+1758"},
+	"LI": {currency: "CHF", languages: []string{"de"}, callingCode: "+423"},
+	"LK": {currency: "LKR", languages: []string{"si", "ta"}, callingCode: "+94"},
+	"LR": {currency: "LRD", languages: []string{"en"}, callingCode: "+231"},
+	"LS": {currency: "LSL", languages: []string{"en", "st"}, callingCode: "+266"},
+	"LT": {currency: "EUR", languages: []string{"lt"}, callingCode: "+370"},
+	"LU": {currency: "EUR", languages: []string{"lb", "fr", "de"}, callingCode: "+352"},
+	"LV": {currency: "EUR", languages: []string{"lv"}, callingCode: "+371"},
+	"LY": {currency: "LYD", languages: []string{"ar"}, callingCode: "+218"},
+	"MA": {currency: "MAD", languages: []string{"ar", "fr"}, callingCode: "+212"},
+	"MC": {currency: "EUR", languages: []string{"fr"}, callingCode: "+377"},
+	"MD": {currency: "MDL", languages: []string{"ro"}, callingCode: "+373"},
+	"ME": {currency: "EUR", languages: []string{"sr"}, callingCode: "+382"},
+	"MF": {currency: "EUR", languages: []string{"fr"}, callingCode: "+590"},
+	"MG": {currency: "MGA", languages: []string{"mg", "fr"}, callingCode: "+261"},
+	"MH": {currency: "USD", languages: []string{"en", "mh"}, callingCode: "+692"},
+	"MK": {currency: "MKD", languages: []string{"mk", "sq"}, callingCode: "+389"},
+	"ML": {currency: "XOF", languages: []string{"fr"}, callingCode: "+223"},
+	"MM": {currency: "MMK", languages: []string{"my"}, callingCode: "+95"},
+	"MN": {currency: "MNT", languages: []string{"mn"}, callingCode: "+976"},
+	"MO": {currency: "MOP", languages: []string{"zh", "pt"}, callingCode: "+853"},
+	"MP": {currency: "USD", languages: []string{"en", "ch"}, callingCode: "+1670"},
+	"MQ": {currency: "EUR", languages: []string{"fr"}, callingCode: "+596"},
+	"MR": {currency: "MRU", languages: []string{"ar"}, callingCode: "+222"},
+	"MS": {currency: "XCD", languages: []string{"en"}, callingCode: "+1664"},
+	"MT": {currency: "EUR", languages: []string{"mt", "en"}, callingCode: "+356"},
+	"MU": {currency: "MUR", languages: []string{"en", "fr"}, callingCode: "+230"},
+	"MV": {currency: "MVR", languages: []string{"dv"}, callingCode: "+960"},
+	"MW": {currency: "MWK", languages: []string{"en", "ny"}, callingCode: "+265"},
+	"MX": {currency: "MXN", languages: []string{"es"}, callingCode: "+52"},
+	"MY": {currency: "MYR", languages: []string{"ms"}, callingCode: "+60"},
+	"MZ": {currency: "MZN", languages: []string{"pt"}, callingCode: "+258"},
+	"NA": {currency: "NAD", languages: []string{"en", "af"}, callingCode: "+264"},
+	"NC": {currency: "XPF", languages: []string{"fr"}, callingCode: "+687"},
+	"NE": {currency: "XOF", languages: []string{"fr"}, callingCode: "+227"},
+	"NF": {currency: "AUD", languages: []string{"en"}, callingCode: "+672"},
+	"NG": {currency: "NGN", languages: []string{"en"}, callingCode: "+234"},
+	"NI": {currency: "NIO", languages: []string{"es"}, callingCode: "+505"},
+	"NL": {currency: "EUR", languages: []string{"nl"}, callingCode: "+31"},
+	"NO": {currency: "NOK", languages: []string{"nb", "nn"}, callingCode: "+47"},
+	"NP": {currency: "NPR", languages: []string{"ne"}, callingCode: "+977"},
+	"NR": {currency: "AUD", languages: []string{"na", "en"}, callingCode: "+674"},
+	"NU": {currency: "NZD", languages: []string{"en"}, callingCode: "+683"},
+	"NZ": {currency: "NZD", languages: []string{"en", "mi"}, callingCode: "+64"},
+	"OM": {currency: "OMR", languages: []string{"ar"}, callingCode: "+968"},
+	"PA": {currency: "PAB", languages: []string{"es"}, callingCode: "+507"},
+	"PE": {currency: "PEN", languages: []string{"es", "qu"}, callingCode: "+51"},
+	"PF": {currency: "XPF", languages: []string{"fr"}, callingCode: "+689"},
+	"PG": {currency: "PGK", languages: []string{"en", "tpi"}, callingCode: "+675"},
+	"PH": {currency: "PHP", languages: []string{"fil", "en"}, callingCode: "+63"},
+	"PK": {currency: "PKR", languages: []string{"ur", "en"}, callingCode: "+92"},
+	"PL": {currency: "PLN", languages: []string{"pl"}, callingCode: "+48"},
+	"PM": {currency: "EUR", languages: []string{"fr"}, callingCode: "+508"},
+	"PN": {currency: "NZD", languages: []string{"en"}, callingCode: "+64"},
+	"PR": {currency: "USD", languages: []string{"es", "en"}, callingCode: "+1787"},
+	"PS": {currency: "ILS", languages: []string{"ar"}, callingCode: "+970"},
+	"PT": {currency: "EUR", languages: []string{"pt"}, callingCode: "+351"},
+	"PW": {currency: "USD", languages: []string{"en"}, callingCode: "+680"},
+	"PY": {currency: "PYG", languages: []string{"es", "gn"}, callingCode: "+595"},
+	"QA": {currency: "QAR", languages: []string{"ar"}, callingCode: "+974"},
+	"RE": {currency: "EUR", languages: []string{"fr"}, callingCode: "+262"},
+	"RO": {currency: "RON", languages: []string{"ro"}, callingCode: "+40"},
+	"RS": {currency: "RSD", languages: []string{"sr"}, callingCode: "+381"},
+	"RU": {currency: "RUB", languages: []string{"ru"}, callingCode: "+7"},
+	"RW": {currency: "RWF", languages: []string{"rw", "en", "fr"}, callingCode: "+250"},
+	"SA": {currency: "SAR", languages: []string{"ar"}, callingCode: "+966"},
+	"SB": {currency: "SBD", languages: []string{"en"}, callingCode: "+677"},
+	"SC": {currency: "SCR", languages: []string{"fr", "en"}, callingCode: "+248"},
+	"SD": {currency: "SDG", languages: []string{"ar", "en"}, callingCode: "+249"},
+	"SE": {currency: "SEK", languages: []string{"sv"}, callingCode: "+46"},
+	"SG": {currency: "SGD", languages: []string{"en", "ms", "zh", "ta"}, callingCode: "+65"},
+	"SH": {currency: "SHP", languages: []string{"en"}, callingCode: "+290"},
+	"SI": {currency: "EUR", languages: []string{"sl"}, callingCode: "+386"},
+	"SJ": {currency: "NOK", languages: []string{"nb"}, callingCode: "+47"},
+	"SK": {currency: "EUR", languages: []string{"sk"}, callingCode: "+421"},
+	"SL": {currency: "SLE", languages: []string{"en"}, callingCode: "+232"},
+	"SM": {currency: "EUR", languages: []string{"it"}, callingCode: "+378"},
+	"SN": {currency: "XOF", languages: []string{"fr"}, callingCode: "+221"},
+	"SO": {currency: "SOS", languages: []string{"so", "ar"}, callingCode: "+252"},
+	"SR": {currency: "SRD", languages: []string{"nl"}, callingCode: "+597"},
+	"SS": {currency: "SSP", languages: []string{"en"}, callingCode: "+211"},
+	"ST": {currency: "STN", languages: []string{"pt"}, callingCode: "+239"},
+	"SV": {currency: "USD", languages: []string{"es"}, callingCode: "+503"},
+	"SX": {currency: "ANG", languages: []string{"nl", "en"}, callingCode: "+1721"},
+	"SY": {currency: "SYP", languages: []string{"ar"}, callingCode: "+963"},
+	"SZ": {currency: "SZL", languages: []string{"en", "ss"}, callingCode: "+268"},
+	"TC": {currency: "USD", languages: []string{"en"}, callingCode: "+1649"},
+	"TD": {currency: "XAF", languages: []string{"fr", "ar"}, callingCode: "+235"},
+	"TF": {currency: "EUR", languages: []string{"fr"}, callingCode: "+262"},
+	"TG": {currency: "XOF", languages: []string{"fr"}, callingCode: "+228"},
+	"TH": {currency: "THB", languages: []string{"th"}, callingCode: "+66"},
+	"TJ": {currency: "TJS", languages: []string{"tg"}, callingCode: "+992"},
+	"TK": {currency: "NZD", languages: []string{"en"}, callingCode: "+690"},
+	"TL": {currency: "USD", languages: []string{"pt", "tet"}, callingCode: "+670"},
+	"TM": {currency: "TMT", languages: []string{"tk"}, callingCode: "+993"},
+	"TN": {currency: "TND", languages: []string{"ar", "fr"}, callingCode: "+216"},
+	"TO": {currency: "TOP", languages: []string{"to", "en"}, callingCode: "+676"},
+	"TR": {currency: "TRY", languages: []string{"tr"}, callingCode: "+90"},
+	"TT": {currency: "TTD", languages: []string{"en"}, callingCode: "+1868"},
+	"TV": {currency: "AUD", languages: []string{"en"}, callingCode: "+688"},
+	"TW": {currency: "TWD", languages: []string{"zh"}, callingCode: "+886"},
+	"TZ": {currency: "TZS", languages: []string{"sw", "en"}, callingCode: "+255"},
+	"UA": {currency: "UAH", languages: []string{"uk"}, callingCode: "+380"},
+	"UG": {currency: "UGX", languages: []string{"en", "sw"}, callingCode: "+256"},
+	"UM": {currency: "USD", languages: []string{"en"}, callingCode: "+1"},
+	"US": {currency: "USD", languages: []string{"en", "es"}, callingCode: "+1"},
+	"UY": {currency: "UYU", languages: []string{"es"}, callingCode: "+598"},
+	"UZ": {currency: "UZS", languages: []string{"uz"}, callingCode: "+998"},
+	"VA": {currency: "EUR", languages: []string{"it", "la"}, callingCode: "+379"},
+	"VC": {currency: "XCD", languages: []string{"en"}, callingCode: "+1784"},
+	"VE": {currency: "VES", languages: []string{"es"}, callingCode: "+58"},
+	"VG": {currency: "USD", languages: []string{"en"}, callingCode: "+1284"},
+	"VI": {currency: "USD", languages: []string{"en"}, callingCode: "+1340"},
+	"VN": {currency: "VND", languages: []string{"vi"}, callingCode: "+84"},
+	"VU": {currency: "VUV", languages: []string{"bi", "en", "fr"}, callingCode: "+678"},
+	"WF": {currency: "XPF", languages: []string{"fr"}, callingCode: "+681"},
+	"WS": {currency: "WST", languages: []string{"sm", "en"}, callingCode: "+685"},
+	"XK": {currency: "EUR", languages: []string{"sq", "sr"}, callingCode: "+383"},
+	"YE": {currency: "YER", languages: []string{"ar"}, callingCode: "+967"},
+	"YT": {currency: "EUR", languages: []string{"fr"}, callingCode: "+262"},
+	"ZA": {currency: "ZAR", languages: []string{"en", "af", "zu", "xh"}, callingCode: "+27"},
+	"ZM": {currency: "ZMW", languages: []string{"en"}, callingCode: "+260"},
+	"ZW": {currency: "ZWG", languages: []string{"en", "sn", "nd"}, callingCode: "+263"},
+}
@@ -0,0 +1,93 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// DataHeader header carrying all geo data in a single value.
+	DataHeader = "GeoIP-Data"
+	// DataHeaderVersion version of the DataHeader payload, sent as its "v" key.
+	DataHeaderVersion = 1
+	// DataHeaderFormatJSON sends the payload as compact JSON.
+	DataHeaderFormatJSON = "json"
+	// DataHeaderFormatBase64 sends the payload as unpadded base64url encoded JSON.
+	DataHeaderFormatBase64 = "base64"
+)
+
+// DataHeaderConfig configures the single structured geo header.
+type DataHeaderConfig struct {
+	// Format of the header, either "json" or "base64". The header is disabled when empty.
+	Format string `json:"format,omitempty"`
+	// Fields to include in the payload. All known fields are included when empty.
+	Fields []string `json:"fields,omitempty"`
+}
+
+// dataHeader encodes lookup results into the DataHeader.
+type dataHeader struct {
+	base64 bool
+	fields map[string]bool
+}
+
+// newDataHeader validates the config and creates the encoder. Returns nil when the header is disabled.
+func newDataHeader(cfg DataHeaderConfig) (*dataHeader, error) {
+	if cfg.Format == "" {
+		return nil, nil //nolint:nilnil
+	}
+	if cfg.Format != DataHeaderFormatJSON && cfg.Format != DataHeaderFormatBase64 {
+		return nil, fmt.Errorf("invalid dataHeader format: format=%s", cfg.Format)
+	}
+
+	header := &dataHeader{base64: cfg.Format == DataHeaderFormatBase64}
+	if len(cfg.Fields) == 0 {
+		return header, nil
+	}
+
+	valid := map[string]bool{}
+	for _, field := range (&GeoIPResult{}).allFields() {
+		valid[field.name] = true
+	}
+	header.fields = map[string]bool{}
+	for _, name := range cfg.Fields {
+		if !valid[name] {
+			return nil, fmt.Errorf("invalid dataHeader field: field=%s", name)
+		}
+		header.fields[name] = true
+	}
+
+	return header, nil
+}
+
+// encode serializes the selected known fields of the result along with the payload version.
+func (h *dataHeader) encode(result *GeoIPResult) (string, error) {
+	payload := map[string]interface{}{"v": DataHeaderVersion}
+	for _, field := range result.fields() {
+		if h.fields == nil || h.fields[field.name] {
+			payload[field.name] = field.value
+		}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("%w", err)
+	}
+	if h.base64 {
+		return base64.RawURLEncoding.EncodeToString(data), nil
+	}
+
+	return string(data), nil
+}
+
+// set adds the header to the request.
+func (h *dataHeader) set(req *http.Request, result *GeoIPResult) error {
+	value, err := h.encode(result)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(DataHeader, value)
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDataHeaderJSON(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.DataHeader.Format = DataHeaderFormatJSON
+	cfg.DataHeader.Fields = []string{"countryCode", "city", "geohash"}
+	instance := newTestMiddleware(t, cfg, munichResult())
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+	req.RemoteAddr = "188.193.88.199:9999"
+	instance.ServeHTTP(httptest.NewRecorder(), req)
+
+	expected := `{"city":"Munich","countryCode":"DE","geohash":"u284p0rv0cje","v":1}`
+	if req.Header.Get(DataHeader) != expected {
+		t.Fatalf("invalid data header '%s'", req.Header.Get(DataHeader))
+	}
+}
+
+func TestDataHeaderBase64(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.DataHeader.Format = DataHeaderFormatBase64
+	cfg.DataHeader.Fields = []string{"countryCode"}
+	instance := newTestMiddleware(t, cfg, munichResult())
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+	req.RemoteAddr = "188.193.88.199:9999"
+	instance.ServeHTTP(httptest.NewRecorder(), req)
+
+	decoded, err := base64.RawURLEncoding.DecodeString(req.Header.Get(DataHeader))
+	if err != nil {
+		t.Fatalf("invalid base64url: %v", err)
+	}
+	if string(decoded) != `{"countryCode":"DE","v":1}` {
+		t.Fatalf("invalid data header payload '%s'", decoded)
+	}
+}
+
+func TestDataHeaderInvalidConfig(t *testing.T) {
+	if _, err := newDataHeader(DataHeaderConfig{Format: "xml"}); err == nil {
+		t.Fatalf("Must fail on invalid format")
+	}
+	if _, err := newDataHeader(DataHeaderConfig{Format: DataHeaderFormatJSON, Fields: []string{"zip"}}); err == nil {
+		t.Fatalf("Must fail on invalid field")
+	}
+}
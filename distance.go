@@ -0,0 +1,18 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import "math"
+
+// earthRadiusKm mean radius of the Earth in kilometers.
+const earthRadiusKm = 6371.0088
+
+// haversineKm returns the great-circle distance in kilometers between two points.
+func haversineKm(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLng := (lng2 - lng1) * toRad
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
@@ -0,0 +1,344 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+)
+
+const (
+	// GeofencesHeader header listing the names of the geofences containing the client.
+	GeofencesHeader = "GeoIP-Geofences"
+	// geofenceCellSize size, in degrees, of the cells of the geofence index.
+	geofenceCellSize = 1.0
+	// geofenceMaxCells number of index cells above which a shape is checked for every lookup instead.
+	geofenceMaxCells = 4096
+	// kmPerDegree length of a degree of latitude in kilometers.
+	kmPerDegree = 111.32
+)
+
+// GeofenceConfig a named area, either a circle or the polygons of a GeoJSON file.
+type GeofenceConfig struct {
+	// Name of the fence. When empty, each GeoJSON feature becomes its own fence named after its "name" property.
+	Name string `json:"name,omitempty"`
+	// Latitude of the center of a circular fence.
+	Latitude float64 `json:"latitude,omitempty"`
+	// Longitude of the center of a circular fence.
+	Longitude float64 `json:"longitude,omitempty"`
+	// RadiusKm of a circular fence.
+	RadiusKm float64 `json:"radiusKm,omitempty"`
+	// GeoJSONPath to a file with Polygon or MultiPolygon geometries.
+	GeoJSONPath string `json:"geoJSONPath,omitempty"` //nolint:tagliatelle
+}
+
+// geoShape an area that may contain a point.
+type geoShape interface {
+	bounds() Box
+	contains(lat, lng float64) bool
+}
+
+// circle a circular area on the surface of the Earth.
+type circle struct {
+	lat, lng, radiusKm float64
+}
+
+func (c *circle) bounds() Box {
+	latDelta := c.radiusKm / kmPerDegree
+	lngDelta := 360.0
+	// Circles reaching over a pole span every longitude.
+	if cos := math.Cos(c.lat * math.Pi / 180); cos > 0 && c.lat+latDelta < 90 && c.lat-latDelta > -90 {
+		lngDelta = math.Min(360, latDelta/cos)
+	}
+	return Box{
+		MinLat: math.Max(-90, c.lat-latDelta),
+		MaxLat: math.Min(90, c.lat+latDelta),
+		MinLng: c.lng - lngDelta,
+		MaxLng: c.lng + lngDelta,
+	}
+}
+
+func (c *circle) contains(lat, lng float64) bool {
+	return haversineKm(c.lat, c.lng, lat, lng) <= c.radiusKm
+}
+
+// polygon an area bounded by an outer ring, minus its holes. Rings are lists of [lng, lat] points.
+type polygon struct {
+	rings [][][2]float64
+	box   Box
+}
+
+func newPolygon(rings [][][2]float64) (*polygon, error) {
+	if len(rings) == 0 || len(rings[0]) < 3 {
+		return nil, fmt.Errorf("polygon must have an outer ring with at least 3 points")
+	}
+	box := Box{MinLat: 90, MaxLat: -90, MinLng: 180, MaxLng: -180}
+	for _, point := range rings[0] {
+		box.MinLng = math.Min(box.MinLng, point[0])
+		box.MaxLng = math.Max(box.MaxLng, point[0])
+		box.MinLat = math.Min(box.MinLat, point[1])
+		box.MaxLat = math.Max(box.MaxLat, point[1])
+	}
+	return &polygon{rings: rings, box: box}, nil
+}
+
+func (p *polygon) bounds() Box {
+	return p.box
+}
+
+func (p *polygon) contains(lat, lng float64) bool {
+	if !p.box.Contains(lat, lng) || !ringContains(p.rings[0], lat, lng) {
+		return false
+	}
+	for _, hole := range p.rings[1:] {
+		if ringContains(hole, lat, lng) {
+			return false
+		}
+	}
+	return true
+}
+
+// ringContains checks if the point is inside the ring using ray casting.
+func ringContains(ring [][2]float64, lat, lng float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lng < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// fenceShape a shape belonging to a named fence.
+type fenceShape struct {
+	fence int
+	shape geoShape
+}
+
+// geofenceIndex finds the fences containing a point.
+// Shapes are bucketed into a grid of cells by their bounding box, so a lookup only checks the shapes of a single cell.
+type geofenceIndex struct {
+	names []string
+	cells map[int][]fenceShape
+	large []fenceShape
+}
+
+// newGeofenceIndex loads the fences and indexes their shapes.
+func newGeofenceIndex(configs []GeofenceConfig) (*geofenceIndex, error) {
+	index := &geofenceIndex{cells: map[int][]fenceShape{}}
+
+	for i, cfg := range configs {
+		if cfg.GeoJSONPath != "" {
+			features, err := loadGeoJSON(cfg.GeoJSONPath)
+			if err != nil {
+				return nil, fmt.Errorf("invalid geofence %d: %w", i, err)
+			}
+			for _, feature := range features {
+				name := cfg.Name
+				if name == "" {
+					name = feature.name
+				}
+				if name == "" {
+					return nil, fmt.Errorf("invalid geofence %d: feature without a name in %s", i, cfg.GeoJSONPath)
+				}
+				for _, shape := range feature.shapes {
+					index.add(name, shape)
+				}
+			}
+			continue
+		}
+
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("invalid geofence %d: missing name", i)
+		}
+		if cfg.RadiusKm <= 0 {
+			return nil, fmt.Errorf("invalid geofence %s: radiusKm must be positive", cfg.Name)
+		}
+		index.add(cfg.Name, &circle{lat: cfg.Latitude, lng: cfg.Longitude, radiusKm: cfg.RadiusKm})
+	}
+
+	return index, nil
+}
+
+// cellKey returns the key of the cell containing the point.
+func cellKey(lat, lng float64) int {
+	cols := int(360 / geofenceCellSize)
+	row := int(math.Floor((lat + 90) / geofenceCellSize))
+	// The column stays inside the row, whatever the rounding of the longitude.
+	col := int(math.Floor((wrapLng(lng) + 180) / geofenceCellSize))
+	if col >= cols {
+		col = cols - 1
+	}
+	return row*cols + col
+}
+
+// add indexes a shape of the named fence.
+func (idx *geofenceIndex) add(name string, shape geoShape) {
+	fence := -1
+	for i, existing := range idx.names {
+		if existing == name {
+			fence = i
+		}
+	}
+	if fence < 0 {
+		fence = len(idx.names)
+		idx.names = append(idx.names, name)
+	}
+	entry := fenceShape{fence: fence, shape: shape}
+
+	box := shape.bounds()
+	rows := int(math.Floor(box.MaxLat/geofenceCellSize) - math.Floor(box.MinLat/geofenceCellSize) + 1)
+	cols := int(math.Floor(box.MaxLng/geofenceCellSize) - math.Floor(box.MinLng/geofenceCellSize) + 1)
+	if rows*cols > geofenceMaxCells {
+		idx.large = append(idx.large, entry)
+		return
+	}
+
+	keys := map[int]bool{}
+	for lat := box.MinLat; lat < box.MaxLat+geofenceCellSize; lat += geofenceCellSize {
+		for lng := box.MinLng; lng < box.MaxLng+geofenceCellSize; lng += geofenceCellSize {
+			key := cellKey(math.Min(lat, box.MaxLat), math.Min(lng, box.MaxLng))
+			if !keys[key] {
+				keys[key] = true
+				idx.cells[key] = append(idx.cells[key], entry)
+			}
+		}
+	}
+}
+
+// match returns the names of the fences containing the point, in configuration order.
+func (idx *geofenceIndex) match(lat, lng float64) []string {
+	matched := make([]bool, len(idx.names))
+	check := func(entries []fenceShape) {
+		for _, entry := range entries {
+			if !matched[entry.fence] && entry.shape.contains(lat, lng) {
+				matched[entry.fence] = true
+			}
+		}
+	}
+	check(idx.cells[cellKey(lat, lng)])
+	check(idx.large)
+
+	names := []string{}
+	for i, ok := range matched {
+		if ok {
+			names = append(names, idx.names[i])
+		}
+	}
+	return names
+}
+
+// geoJSONFeature the shapes of a single GeoJSON feature.
+type geoJSONFeature struct {
+	name   string
+	shapes []geoShape
+}
+
+// geoJSONObject the subset of GeoJSON objects used for geofences.
+type geoJSONObject struct {
+	Type        string                 `json:"type"`
+	Features    []geoJSONObject        `json:"features"`
+	Geometry    *geoJSONObject         `json:"geometry"`
+	Properties  map[string]interface{} `json:"properties"`
+	Coordinates json.RawMessage        `json:"coordinates"`
+}
+
+// loadGeoJSON reads the Polygon and MultiPolygon geometries of a GeoJSON file.
+func loadGeoJSON(path string) ([]geoJSONFeature, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	var root geoJSONObject
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON %s: %w", path, err)
+	}
+
+	objects := []geoJSONObject{root}
+	if root.Type == "FeatureCollection" {
+		objects = root.Features
+	}
+
+	features := []geoJSONFeature{}
+	for _, object := range objects {
+		feature := geoJSONFeature{}
+		geometry := &object
+		if object.Type == "Feature" {
+			if name, ok := object.Properties["name"].(string); ok {
+				feature.name = name
+			}
+			geometry = object.Geometry
+		}
+		if geometry == nil {
+			continue
+		}
+
+		polygons := [][][][2]float64{}
+		switch geometry.Type {
+		case "Polygon":
+			var rings [][][2]float64
+			if err := json.Unmarshal(geometry.Coordinates, &rings); err != nil {
+				return nil, fmt.Errorf("invalid Polygon in %s: %w", path, err)
+			}
+			polygons = append(polygons, rings)
+		case "MultiPolygon":
+			if err := json.Unmarshal(geometry.Coordinates, &polygons); err != nil {
+				return nil, fmt.Errorf("invalid MultiPolygon in %s: %w", path, err)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported GeoJSON geometry in %s: type=%s", path, geometry.Type)
+		}
+
+		for _, rings := range polygons {
+			shape, err := newPolygon(rings)
+			if err != nil {
+				return nil, fmt.Errorf("invalid polygon in %s: %w", path, err)
+			}
+			feature.shapes = append(feature.shapes, shape)
+		}
+		features = append(features, feature)
+	}
+
+	return features, nil
+}
+
+// geofenceSet validates that the names belong to configured fences.
+func geofenceSet(idx *geofenceIndex, names []string) (map[string]bool, error) {
+	set := map[string]bool{}
+	for _, name := range names {
+		found := false
+		for _, existing := range idx.names {
+			found = found || existing == name
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown geofence: name=%s", name)
+		}
+		set[name] = true
+	}
+	return set, nil
+}
+
+// checkGeofences denies requests inside a denied fence, or outside every allowed fence.
+// Requests without a known location are never denied.
+func (mw *TraefikGeoIP) checkGeofences(result *GeoIPResult) *decision {
+	if !result.hasCoordinates() {
+		return nil
+	}
+
+	allowed := len(mw.allowGeofences) == 0
+	for _, name := range result.geofences {
+		if mw.denyGeofences[name] {
+			return &decision{status: http.StatusForbidden, action: decisionDeny, rule: "geofence:" + name}
+		}
+		allowed = allowed || mw.allowGeofences[name]
+	}
+	if !allowed {
+		return &decision{status: http.StatusForbidden, action: decisionDeny, rule: "geofence:outside-allowed"}
+	}
+
+	return nil
+}
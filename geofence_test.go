@@ -0,0 +1,163 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const bavariaGeoJSON = `{
+  "type": "FeatureCollection",
+  "features": [
+    {
+      "type": "Feature",
+      "properties": {"name": "munich-box"},
+      "geometry": {
+        "type": "Polygon",
+        "coordinates": [
+          [[11.3, 48.0], [11.8, 48.0], [11.8, 48.3], [11.3, 48.3], [11.3, 48.0]],
+          [[11.56, 48.16], [11.58, 48.16], [11.58, 48.17], [11.56, 48.17], [11.56, 48.16]]
+        ]
+      }
+    },
+    {
+      "type": "Feature",
+      "properties": {"name": "europe"},
+      "geometry": {
+        "type": "MultiPolygon",
+        "coordinates": [[[[-25, 34], [45, 34], [45, 72], [-25, 72], [-25, 34]]]]
+      }
+    }
+  ]
+}`
+
+func writeGeoJSON(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "fences.geojson")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("unable to write GeoJSON: %v", err)
+	}
+	return path
+}
+
+func TestGeofenceIndex(t *testing.T) {
+	idx, err := newGeofenceIndex([]GeofenceConfig{
+		{Name: "munich", Latitude: 48.137, Longitude: 11.575, RadiusKm: 20},
+		{Name: "fiji", Latitude: -17.7, Longitude: 179.9, RadiusKm: 100},
+		{GeoJSONPath: writeGeoJSON(t, bavariaGeoJSON)},
+	})
+	if err != nil {
+		t.Fatalf("unable to create index: %v", err)
+	}
+
+	cases := []struct {
+		lat, lng float64
+		expected string
+	}{
+		{48.2, 11.5, "munich,munich-box,europe"},
+		// Inside the hole of munich-box.
+		{48.1663, 11.5683, "munich,europe"},
+		{52.52, 13.405, "europe"},
+		{40.71, -74.0, ""},
+		// Across the antimeridian.
+		{-17.7, -179.9, "fiji"},
+	}
+	for _, c := range cases {
+		matched := ""
+		for i, name := range idx.match(c.lat, c.lng) {
+			if i > 0 {
+				matched += ","
+			}
+			matched += name
+		}
+		if matched != c.expected {
+			t.Fatalf("invalid fences of (%v, %v): '%s', not '%s'", c.lat, c.lng, matched, c.expected)
+		}
+	}
+}
+
+func TestGeofenceIndexAntimeridian(t *testing.T) {
+	// Over the pole the circle's bounds span every longitude, from -180 to +540.
+	idx, err := newGeofenceIndex([]GeofenceConfig{{Name: "pole", Latitude: 89, Longitude: 180, RadiusKm: 200}})
+	if err != nil {
+		t.Fatalf("unable to create index: %v", err)
+	}
+
+	// Longitudes that overflowed several times stay in the same row.
+	for _, lng := range []float64{-900, -540, -180, 180, 540, 900} {
+		if key := cellKey(89.5, lng); key != cellKey(89.5, -180) || key/360 != 179 {
+			t.Fatalf("invalid cell of longitude %v: %d", lng, key)
+		}
+	}
+	if key := cellKey(0, math.Nextafter(180, 0)); key/360 != 90 {
+		t.Fatalf("invalid cell of the last longitude: %d", key)
+	}
+
+	for _, point := range [][2]float64{{89.5, 179.9}, {89.5, -179.9}, {89.5, 0}} {
+		if matched := idx.match(point[0], point[1]); len(matched) != 1 {
+			t.Fatalf("invalid fences of %v: %v", point, matched)
+		}
+	}
+	if matched := idx.match(88, 0); len(matched) != 0 {
+		t.Fatalf("invalid fences of the far side: %v", matched)
+	}
+	for key := range idx.cells {
+		if row := key / 360; row < 177 || row > 180 {
+			t.Fatalf("shape indexed outside of its rows: %d", key)
+		}
+	}
+}
+
+func TestGeofenceInvalidConfig(t *testing.T) {
+	invalid := [][]GeofenceConfig{
+		{{Latitude: 1, Longitude: 1, RadiusKm: 1}},
+		{{Name: "zero"}},
+		{{GeoJSONPath: "./missing.geojson"}},
+		{{GeoJSONPath: writeGeoJSON(t, `{"type": "Point", "coordinates": [1, 1]}`)}},
+	}
+	for _, cfg := range invalid {
+		if _, err := newGeofenceIndex(cfg); err == nil {
+			t.Fatalf("Must fail on invalid geofence %+v", cfg)
+		}
+	}
+}
+
+func TestGeofenceHeaderAndPolicy(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Geofences = []GeofenceConfig{
+		{Name: "munich", Latitude: 48.137, Longitude: 11.575, RadiusKm: 20},
+		{Name: "berlin", Latitude: 52.52, Longitude: 13.405, RadiusKm: 30},
+	}
+	instance := newTestMiddleware(t, cfg, munichResult())
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+	req.RemoteAddr = "188.193.88.199:9999"
+	recorder := httptest.NewRecorder()
+	instance.ServeHTTP(recorder, req)
+	assertStatus(t, recorder, http.StatusOK)
+	if req.Header.Get(GeofencesHeader) != "munich" {
+		t.Fatalf("invalid geofences header '%s'", req.Header.Get(GeofencesHeader))
+	}
+
+	cfg.DenyGeofences = []string{"munich"}
+	instance = newTestMiddleware(t, cfg, munichResult())
+	recorder = httptest.NewRecorder()
+	instance.ServeHTTP(recorder, req)
+	assertStatus(t, recorder, http.StatusForbidden)
+
+	cfg.DenyGeofences = nil
+	cfg.AllowGeofences = []string{"berlin"}
+	instance = newTestMiddleware(t, cfg, munichResult())
+	recorder = httptest.NewRecorder()
+	instance.ServeHTTP(recorder, req)
+	assertStatus(t, recorder, http.StatusForbidden)
+
+	cfg.AllowGeofences = []string{"unknown"}
+	if _, err := newMiddleware(nil, cfg, "traefik_geoip", staticLookup(munichResult())); err == nil {
+		t.Fatalf("Must fail on unknown geofence")
+	}
+}
@@ -23,6 +23,12 @@
 
 package traefik_geoip //nolint:revive,stylecheck
 
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
 // base32Encode bits of 64-bit word into a string.
 func base32Encode(x uint64) string {
 	alphabet := "0123456789bcdefghjkmnpqrstuvwxyz"
@@ -88,3 +94,168 @@ func encodeWithPrecision(lat, lng float64, chars uint) string {
 func EncodeGeoHash(lat, lng float64) string {
 	return encodeWithPrecision(lat, lng, 12)
 }
+
+// Box is a geographic bounding box.
+type Box struct {
+	MinLat float64
+	MaxLat float64
+	MinLng float64
+	MaxLng float64
+}
+
+// Center returns the center of the box.
+func (b Box) Center() (float64, float64) {
+	return (b.MinLat + b.MaxLat) / 2.0, (b.MinLng + b.MaxLng) / 2.0
+}
+
+// Contains decides whether (lat, lng) is contained in the box. The containment
+// test is inclusive of the edges and corners.
+func (b Box) Contains(lat, lng float64) bool {
+	return (b.MinLat <= lat && lat <= b.MaxLat &&
+		b.MinLng <= lng && lng <= b.MaxLng)
+}
+
+// base32Decode decodes a geohash string into its integer representation.
+// The second return value is false if the string contains invalid characters.
+func base32Decode(hash string) (uint64, bool) {
+	alphabet := "0123456789bcdefghjkmnpqrstuvwxyz"
+	x := uint64(0)
+	for i := 0; i < len(hash); i++ {
+		c := hash[i]
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		v := strings.IndexByte(alphabet, c)
+		if v < 0 {
+			return 0, false
+		}
+		x = (x << 5) | uint64(v)
+	}
+	return x, true
+}
+
+// Decode the position of x within the range -r to +r.
+func decodeRange(x uint32, r float64) float64 {
+	const exp232 = 4_294_967_296 // 2^32
+
+	p := float64(x) / exp232
+	return 2*r*p - r
+}
+
+// Squash the even bitlevels of X into a 32-bit word. Odd bitlevels of X are
+// ignored, and may take any value.
+func squash(X uint64) uint32 {
+	X &= 0x5555555555555555
+	X = (X | (X >> 1)) & 0x3333333333333333
+	X = (X | (X >> 2)) & 0x0f0f0f0f0f0f0f0f
+	X = (X | (X >> 4)) & 0x00ff00ff00ff00ff
+	X = (X | (X >> 8)) & 0x0000ffff0000ffff
+	X = (X | (X >> 16)) & 0x00000000ffffffff
+	return uint32(X)
+}
+
+// Deinterleave the bits of X into 32-bit words containing the even and odd
+// bitlevels of X, respectively.
+func deinterleave(X uint64) (uint32, uint32) {
+	return squash(X), squash(X >> 1)
+}
+
+// errorWithPrecision returns the size of a cell with the given number of bits,
+// in degrees of latitude and longitude.
+func errorWithPrecision(bits uint) (float64, float64) {
+	latBits := int(bits / 2)
+	lngBits := int(bits) - latBits
+	return math.Ldexp(180.0, -latBits), math.Ldexp(360.0, -lngBits)
+}
+
+// boundingBoxIntWithPrecision returns the region encoded by the integer
+// geohash with the specified precision.
+func boundingBoxIntWithPrecision(hash uint64, bits uint) Box {
+	fullHash := hash << (64 - bits)
+	latInt, lngInt := deinterleave(fullHash)
+	lat := decodeRange(latInt, 90)
+	lng := decodeRange(lngInt, 180)
+	latErr, lngErr := errorWithPrecision(bits)
+	return Box{
+		MinLat: lat,
+		MaxLat: lat + latErr,
+		MinLng: lng,
+		MaxLng: lng + lngErr,
+	}
+}
+
+// DecodeGeoHash returns the region encoded by the given string geohash.
+// Geohashes longer than 12 characters or with invalid characters are rejected.
+func DecodeGeoHash(hash string) (Box, error) {
+	if len(hash) == 0 || len(hash) > 12 {
+		return Box{}, fmt.Errorf("invalid geohash length: hash=%s", hash)
+	}
+	inthash, ok := base32Decode(hash)
+	if !ok {
+		return Box{}, fmt.Errorf("invalid geohash character: hash=%s", hash)
+	}
+	return boundingBoxIntWithPrecision(inthash, uint(5*len(hash))), nil
+}
+
+// wrapLng brings a longitude that overflowed the antimeridian, any number of
+// times, back into the -180 to +180 range.
+func wrapLng(lng float64) float64 {
+	wrapped := math.Mod(lng+180, 360)
+	if wrapped < 0 {
+		wrapped += 360
+	}
+	return wrapped - 180
+}
+
+// clampLat keeps a latitude inside the -90 to +90 range, mapping the poles to
+// the last representable cell.
+func clampLat(lat float64) float64 {
+	return math.Max(-90, math.Min(lat, math.Nextafter(90, 0)))
+}
+
+// GeoHashNeighbors returns the geohashes of the 8 cells surrounding the given
+// geohash, in the order N, NE, E, SE, S, SW, W, NW. Cells beyond the poles are
+// clamped to the polar cell.
+func GeoHashNeighbors(hash string) ([]string, error) {
+	box, err := DecodeGeoHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	lat, lng := box.Center()
+	latDelta := box.MaxLat - box.MinLat
+	lngDelta := box.MaxLng - box.MinLng
+	chars := uint(len(hash))
+	neighbor := func(dLat, dLng float64) string {
+		return encodeWithPrecision(clampLat(lat+dLat*latDelta), wrapLng(lng+dLng*lngDelta), chars)
+	}
+	return []string{
+		neighbor(1, 0),   // N
+		neighbor(1, 1),   // NE
+		neighbor(0, 1),   // E
+		neighbor(-1, 1),  // SE
+		neighbor(-1, 0),  // S
+		neighbor(-1, -1), // SW
+		neighbor(0, -1),  // W
+		neighbor(1, -1),  // NW
+	}, nil
+}
+
+// geohashCellWidthsKm is the width of a geohash cell at the equator for each
+// number of characters of precision, starting at 1.
+var geohashCellWidthsKm = [12]float64{
+	5009.4, 1252.3, 156.5, 39.1, 4.89, 1.22, 0.153, 0.0382, 0.00477, 0.00119, 0.000149, 0.0000372,
+}
+
+// GeoHashPrecisionForRadius returns the number of characters of the most
+// precise geohash whose cells are still at least as wide as the given radius.
+// It never returns less than 1 or more than 12 characters.
+func GeoHashPrecisionForRadius(radiusKm float64) uint {
+	precision := uint(1)
+	for i, width := range geohashCellWidthsKm {
+		if width < radiusKm {
+			break
+		}
+		precision = uint(i + 1)
+	}
+	return precision
+}
@@ -0,0 +1,95 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEncodeGeoHash(t *testing.T) {
+	cases := []struct {
+		lat, lng float64
+		chars    uint
+		hash     string
+	}{
+		{48.1663, 11.5683, 12, "u284p0rv0cje"},
+		{42.6, -5.6, 5, "ezs42"},
+		{57.64911, 10.40744, 11, "u4pruydqqvj"},
+		{37.751, -97.822, 12, "9ydqy025w0qn"},
+	}
+	for _, c := range cases {
+		if hash := encodeWithPrecision(c.lat, c.lng, c.chars); hash != c.hash {
+			t.Fatalf("invalid geohash of (%v, %v): '%s', not '%s'", c.lat, c.lng, hash, c.hash)
+		}
+	}
+}
+
+func TestDecodeGeoHash(t *testing.T) {
+	cases := []struct {
+		hash string
+		box  Box
+	}{
+		{"ezs42", Box{MinLat: 42.5830078125, MaxLat: 42.626953125, MinLng: -5.625, MaxLng: -5.5810546875}},
+		{"s", Box{MinLat: 0, MaxLat: 45, MinLng: 0, MaxLng: 45}},
+		{"9q8yyk8y", Box{
+			MinLat: 37.77477264404297, MaxLat: 37.77494430541992,
+			MinLng: -122.41962432861328, MaxLng: -122.41928100585938,
+		}},
+	}
+	for _, c := range cases {
+		box, err := DecodeGeoHash(c.hash)
+		if err != nil {
+			t.Fatalf("unable to decode '%s': %v", c.hash, err)
+		}
+		if math.Abs(box.MinLat-c.box.MinLat) > 1e-9 || math.Abs(box.MaxLat-c.box.MaxLat) > 1e-9 ||
+			math.Abs(box.MinLng-c.box.MinLng) > 1e-9 || math.Abs(box.MaxLng-c.box.MaxLng) > 1e-9 {
+			t.Fatalf("invalid box of '%s': %+v, not %+v", c.hash, box, c.box)
+		}
+	}
+
+	box, _ := DecodeGeoHash("u284p0rv0cje")
+	if !box.Contains(48.1663, 11.5683) {
+		t.Fatalf("box must contain the encoded point")
+	}
+
+	for _, invalid := range []string{"", "u284a", "u284p0rv0cjeu"} {
+		if _, err := DecodeGeoHash(invalid); err == nil {
+			t.Fatalf("must fail to decode '%s'", invalid)
+		}
+	}
+}
+
+func TestGeoHashNeighbors(t *testing.T) {
+	cases := map[string][]string{
+		"dqcjq": {"dqcjw", "dqcjx", "dqcjr", "dqcjp", "dqcjn", "dqcjj", "dqcjm", "dqcjt"},
+		"u284p": {"u284r", "u2862", "u2860", "u283b", "u281z", "u281y", "u284n", "u284q"},
+		// Crosses the antimeridian.
+		"xbpbp": {"xbpbr", "80002", "80000", "2pbpb", "rzzzz", "rzzzy", "xbpbn", "xbpbq"},
+	}
+	for hash, expected := range cases {
+		neighbors, err := GeoHashNeighbors(hash)
+		if err != nil {
+			t.Fatalf("unable to get neighbors of '%s': %v", hash, err)
+		}
+		for i := range expected {
+			if neighbors[i] != expected[i] {
+				t.Fatalf("invalid neighbors of '%s': %v, not %v", hash, neighbors, expected)
+			}
+		}
+	}
+}
+
+func TestGeoHashPrecisionForRadius(t *testing.T) {
+	cases := map[float64]uint{
+		10000: 1,
+		1000:  2,
+		100:   3,
+		20:    4,
+		1:     6,
+		0:     12,
+	}
+	for radius, expected := range cases {
+		if precision := GeoHashPrecisionForRadius(radius); precision != expected {
+			t.Fatalf("invalid precision for %vkm: %d, not %d", radius, precision, expected)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// GroupsHeader header listing the country groups of the client's country.
+	GroupsHeader = "GeoIP-Groups"
+	// countryGroupPrefix marks a group reference, such as "@EU", wherever country codes are configured.
+	countryGroupPrefix = "@"
+	// countryGroupsVersion date the built-in groups were last checked against their official lists.
+	countryGroupsVersion = "2025-01-01"
+)
+
+// builtinCountryGroupNames the built-in groups, in the order they are listed in the GroupsHeader.
+var builtinCountryGroupNames = []string{
+	"EU", "EEA", "Schengen", "DACH", "GDPR", "OFAC",
+	"Africa", "Antarctica", "Asia", "Europe", "NorthAmerica", "Oceania", "SouthAmerica",
+}
+
+// euCountries European Union member states.
+var euCountries = []string{
+	"AT", "BE", "BG", "CY", "CZ", "DE", "DK", "EE", "ES", "FI", "FR", "GR", "HR", "HU",
+	"IE", "IT", "LT", "LU", "LV", "MT", "NL", "PL", "PT", "RO", "SE", "SI", "SK",
+}
+
+// eeaCountries European Economic Area: the EU plus Iceland, Liechtenstein and Norway.
+var eeaCountries = concatCountries(euCountries, []string{"IS", "LI", "NO"})
+
+// concatCountries joins lists of country codes into a new list.
+func concatCountries(lists ...[]string) []string {
+	countries := []string{}
+	for _, list := range lists {
+		countries = append(countries, list...)
+	}
+	return countries
+}
+
+// builtinCountryGroups the members of the built-in groups.
+var builtinCountryGroups = map[string][]string{
+	"EU":  euCountries,
+	"EEA": eeaCountries,
+	// Schengen Area members.
+	"Schengen": {
+		"AT", "BE", "BG", "CH", "CZ", "DE", "DK", "EE", "ES", "FI", "FR", "GR", "HR", "HU",
+		"IS", "IT", "LI", "LT", "LU", "LV", "MT", "NL", "NO", "PL", "PT", "RO", "SE", "SI", "SK",
+	},
+	// Germany, Austria and Switzerland.
+	"DACH": {"DE", "AT", "CH"},
+	// GDPR-adjacent: the EEA, where the GDPR applies, plus the United Kingdom and Switzerland,
+	// whose data protection laws mirror it.
+	"GDPR": concatCountries(eeaCountries, []string{"GB", "CH"}),
+	// Countries under comprehensive OFAC sanctions.
+	"OFAC": {"CU", "IR", "KP", "SY"},
+
+	"Africa": {
+		"AO", "BF", "BI", "BJ", "BW", "CD", "CF", "CG", "CI", "CM", "CV", "DJ", "DZ", "EG", "EH", "ER",
+		"ET", "GA", "GH", "GM", "GN", "GQ", "GW", "KE", "KM", "LR", "LS", "LY", "MA", "MG", "ML", "MR",
+		"MU", "MW", "MZ", "NA", "NE", "NG", "RE", "RW", "SC", "SD", "SH", "SL", "SN", "SO", "SS", "ST",
+		"SZ", "TD", "TG", "TN", "TZ", "UG", "YT", "ZA", "ZM", "ZW",
+	},
+	"Antarctica": {"AQ", "BV", "GS", "HM", "TF"},
+	"Asia": {
+		"AE", "AF", "AM", "AZ", "BD", "BH", "BN", "BT", "CC", "CN", "CX", "CY", "GE", "HK", "ID", "IL",
+		"IN", "IO", "IQ", "IR", "JO", "JP", "KG", "KH", "KP", "KR", "KW", "KZ", "LA", "LB", "LK", "MM",
+		"MN", "MO", "MV", "MY", "NP", "OM", "PH", "PK", "PS", "QA", "SA", "SG", "SY", "TH", "TJ", "TL",
+		"TM", "TR", "TW", "UZ", "VN", "YE",
+	},
+	"Europe": {
+		"AD", "AL", "AT", "AX", "BA", "BE", "BG", "BY", "CH", "CZ", "DE", "DK", "EE", "ES", "FI", "FO",
+		"FR", "GB", "GG", "GI", "GR", "HR", "HU", "IE", "IM", "IS", "IT", "JE", "LI", "LT", "LU", "LV",
+		"MC", "MD", "ME", "MK", "MT", "NL", "NO", "PL", "PT", "RO", "RS", "RU", "SE", "SI", "SJ", "SK",
+		"SM", "UA", "VA", "XK",
+	},
+	"NorthAmerica": {
+		"AG", "AI", "AW", "BB", "BL", "BM", "BQ", "BS", "BZ", "CA", "CR", "CU", "CW", "DM", "DO", "GD",
+		"GL", "GP", "GT", "HN", "HT", "JM", "KN", "KY", "LC", "MF", "MQ", "MS", "MX", "NI", "PA", "PM",
+		"PR", "SV", "SX", "TC", "TT", "US", "VC", "VG", "VI",
+	},
+	"Oceania": {
+		"AS", "AU", "CK", "FJ", "FM", "GU", "KI", "MH", "MP", "NC", "NF", "NR", "NU", "NZ", "PF", "PG",
+		"PN", "PW", "SB", "TK", "TO", "TV", "UM", "VU", "WF", "WS",
+	},
+	"SouthAmerica": {"AR", "BO", "BR", "CL", "CO", "EC", "FK", "GF", "GY", "PE", "PY", "SR", "UY", "VE"},
+}
+
+// countryGroups the built-in and user-defined groups of country codes.
+type countryGroups struct {
+	// names of the groups, keyed by their uppercase name.
+	names map[string]string
+	// members of the groups, keyed by their uppercase name.
+	members map[string][]string
+	// byCountry the names of the groups of each country, in header order.
+	byCountry map[string][]string
+}
+
+// newCountryGroups merges the user-defined groups with the built-in ones.
+// User-defined groups may reference other groups, and replace built-in groups of the same name.
+func newCountryGroups(custom map[string][]string) (*countryGroups, error) {
+	groups := &countryGroups{
+		names:     map[string]string{},
+		members:   map[string][]string{},
+		byCountry: map[string][]string{},
+	}
+
+	order := append([]string{}, builtinCountryGroupNames...)
+	definitions := map[string][]string{}
+	for _, name := range builtinCountryGroupNames {
+		definitions[strings.ToUpper(name)] = builtinCountryGroups[name]
+	}
+
+	customNames := make([]string, 0, len(custom))
+	for name := range custom {
+		customNames = append(customNames, name)
+	}
+	sort.Strings(customNames)
+	for _, name := range customNames {
+		if name == "" || strings.ContainsAny(name, countryGroupPrefix+", ") {
+			return nil, fmt.Errorf("invalid country group name: name=%s", name)
+		}
+		key := strings.ToUpper(name)
+		if _, ok := definitions[key]; !ok {
+			order = append(order, name)
+		}
+		definitions[key] = custom[name]
+	}
+
+	for _, name := range order {
+		groups.names[strings.ToUpper(name)] = name
+	}
+	for _, name := range order {
+		key := strings.ToUpper(name)
+		members, err := groups.resolve(key, definitions, map[string]bool{})
+		if err != nil {
+			return nil, fmt.Errorf("invalid country group %s: %w", name, err)
+		}
+		groups.members[key] = members
+		for _, code := range members {
+			groups.byCountry[code] = append(groups.byCountry[code], name)
+		}
+	}
+
+	return groups, nil
+}
+
+// resolve expands the references of a group definition.
+func (g *countryGroups) resolve(key string, definitions map[string][]string, visiting map[string]bool) ([]string, error) {
+	if visiting[key] {
+		return nil, fmt.Errorf("circular reference to @%s", g.names[key])
+	}
+	visiting[key] = true
+	defer delete(visiting, key)
+
+	seen := map[string]bool{}
+	members := []string{}
+	for _, entry := range definitions[key] {
+		codes := []string{}
+		if strings.HasPrefix(entry, countryGroupPrefix) {
+			ref := strings.ToUpper(strings.TrimPrefix(entry, countryGroupPrefix))
+			if _, ok := definitions[ref]; !ok {
+				return nil, fmt.Errorf("unknown country group: group=%s", entry)
+			}
+			expanded, err := g.resolve(ref, definitions, visiting)
+			if err != nil {
+				return nil, err
+			}
+			codes = expanded
+		} else {
+			code, err := normalizeCountryCode(entry)
+			if err != nil {
+				return nil, err
+			}
+			codes = append(codes, code)
+		}
+		for _, code := range codes {
+			if !seen[code] {
+				seen[code] = true
+				members = append(members, code)
+			}
+		}
+	}
+
+	return members, nil
+}
+
+// normalizeCountryCode validates and uppercases a country code.
+func normalizeCountryCode(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+		return "", fmt.Errorf("invalid country code: code=%s", code)
+	}
+	return code, nil
+}
+
+// isGroup checks if a configured value is a group reference.
+func isGroup(value string) bool {
+	return strings.HasPrefix(strings.TrimSpace(value), countryGroupPrefix)
+}
+
+// expand returns the country codes of a country code or group reference.
+func (g *countryGroups) expand(value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if !isGroup(value) {
+		code, err := normalizeCountryCode(value)
+		if err != nil {
+			return nil, err
+		}
+		return []string{code}, nil
+	}
+
+	members, ok := g.members[strings.ToUpper(strings.TrimPrefix(value, countryGroupPrefix))]
+	if !ok {
+		return nil, fmt.Errorf("unknown country group: group=%s", value)
+	}
+	return members, nil
+}
+
+// of returns the names of the groups of a country.
+func (g *countryGroups) of(countryCode string) []string {
+	return g.byCountry[strings.ToUpper(countryCode)]
+}
+
+// expandKeys maps each country to the config key it belongs to.
+// Keys are country codes, group references, or comma separated lists of both.
+// Country codes take precedence over groups, and groups of earlier keys, in sorted order, over later ones.
+func (g *countryGroups) expandKeys(keys []string) (map[string]string, error) {
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+
+	byCountry := map[string]string{}
+	for _, groupPass := range []bool{false, true} {
+		for _, key := range sorted {
+			for _, value := range strings.Split(key, ",") {
+				if isGroup(value) != groupPass {
+					continue
+				}
+				codes, err := g.expand(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid key %s: %w", key, err)
+				}
+				for _, code := range codes {
+					if _, ok := byCountry[code]; !ok {
+						byCountry[code] = key
+					}
+				}
+			}
+		}
+	}
+
+	return byCountry, nil
+}
@@ -0,0 +1,83 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinCountryGroups(t *testing.T) {
+	groups := builtinGroups(t)
+
+	sizes := map[string]int{"EU": 27, "EEA": 30, "Schengen": 29, "DACH": 3}
+	for name, size := range sizes {
+		members, err := groups.expand("@" + name)
+		if err != nil || len(members) != size {
+			t.Fatalf("invalid members of %s: %v, %v", name, members, err)
+		}
+	}
+
+	// Every country of the locale table belongs to exactly one continent.
+	continents := []string{"Africa", "Antarctica", "Asia", "Europe", "NorthAmerica", "Oceania", "SouthAmerica"}
+	for code := range countries {
+		count := 0
+		for _, name := range groups.of(code) {
+			for _, continent := range continents {
+				if name == continent {
+					count++
+				}
+			}
+		}
+		if count != 1 {
+			t.Fatalf("%s belongs to %d continents: %v", code, count, groups.of(code))
+		}
+	}
+}
+
+func TestCustomCountryGroups(t *testing.T) {
+	groups, err := newCountryGroups(map[string][]string{
+		"Storefronts": {"@dach", "fr"},
+		"EU":          {"DE"},
+	})
+	if err != nil {
+		t.Fatalf("unable to create groups: %v", err)
+	}
+	if members, _ := groups.expand("@storefronts"); strings.Join(members, ",") != "DE,AT,CH,FR" {
+		t.Fatalf("invalid custom group members %v", members)
+	}
+	if members, _ := groups.expand("@EU"); strings.Join(members, ",") != "DE" {
+		t.Fatalf("custom groups must replace built-in ones, got %v", members)
+	}
+
+	invalid := []map[string][]string{
+		{"A": {"@B"}, "B": {"@A"}},
+		{"A": {"@missing"}},
+		{"A": {"DEU"}},
+		{"@A": {"DE"}},
+	}
+	for _, custom := range invalid {
+		if _, err := newCountryGroups(custom); err == nil {
+			t.Fatalf("Must fail on invalid groups %v", custom)
+		}
+	}
+}
+
+func TestCountryGroupsInConfig(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.GroupsHeader = true
+	cfg.Redirects = map[string]string{"@EU": "https://eu.example.com{path}", "FR": "https://fr.example.com{path}"}
+	instance := newTestMiddleware(t, cfg, munichResult())
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.RemoteAddr = "188.193.88.199:9999"
+	recorder := httptest.NewRecorder()
+	instance.ServeHTTP(recorder, req)
+
+	if req.Header.Get(GroupsHeader) != "EU,EEA,Schengen,DACH,GDPR,Europe" {
+		t.Fatalf("invalid groups header '%s'", req.Header.Get(GroupsHeader))
+	}
+	if recorder.Header().Get("Location") != "https://eu.example.com/" {
+		t.Fatalf("invalid group redirect '%s'", recorder.Header().Get("Location"))
+	}
+}
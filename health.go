@@ -0,0 +1,194 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/IncSW/geoip2" //nolint:depguard
+)
+
+const (
+	// DBBuildHeader header with the build time of the database.
+	DBBuildHeader = "GeoIP-DB-Build"
+
+	// StaleDatabaseWarn logs a warning when the database is stale.
+	StaleDatabaseWarn = "warn"
+	// StaleDatabaseDegrade also reports the middleware as degraded on the health endpoint.
+	StaleDatabaseDegrade = "degrade"
+	// StaleDatabaseFail refuses to start with a stale database.
+	StaleDatabaseFail = "fail"
+
+	// HealthOK every database is fresh.
+	HealthOK = "ok"
+	// HealthDegraded a database is stale.
+	HealthDegraded = "degraded"
+)
+
+// database a loaded database and its metadata.
+type database struct {
+	path     string
+	metadata *geoip2.Metadata
+}
+
+// built returns the build time of the database.
+func (d database) built() time.Time {
+	return time.Unix(int64(d.metadata.BuildEpoch), 0).UTC()
+}
+
+// databaseHealth describes a database on the health endpoint.
+type databaseHealth struct {
+	Path         string            `json:"path"`
+	DatabaseType string            `json:"databaseType"`
+	BuildEpoch   uint64            `json:"buildEpoch"`
+	Build        string            `json:"build"`
+	IPVersion    uint16            `json:"ipVersion"`
+	Languages    []string          `json:"languages"`
+	Description  map[string]string `json:"description"`
+	Stale        bool              `json:"stale"`
+}
+
+// health the answer of the health endpoint to trusted callers.
+type health struct {
+	Status    string            `json:"status"`
+	Databases []databaseHealth  `json:"databases"`
+	Metrics   map[string]uint64 `json:"metrics"`
+}
+
+// healthStatus the answer of the health endpoint to other callers.
+type healthStatus struct {
+	Status string `json:"status"`
+}
+
+// validateStaleDatabase checks the database age options, and returns the maximum age.
+func validateStaleDatabase(maxAge, onStale string) (time.Duration, error) {
+	switch onStale {
+	case StaleDatabaseWarn, StaleDatabaseDegrade, StaleDatabaseFail:
+	default:
+		return 0, fmt.Errorf("invalid stale database action: onStaleDatabase=%s", onStale)
+	}
+	if maxAge == "" {
+		return 0, nil
+	}
+
+	age, err := time.ParseDuration(maxAge)
+	if err != nil || age <= 0 {
+		return 0, fmt.Errorf("invalid max database age: maxDatabaseAge=%s", maxAge)
+	}
+	return age, nil
+}
+
+// isStale checks if the database is older than the maximum age.
+func (mw *TraefikGeoIP) isStale(db database) bool {
+	return mw.maxDatabaseAge > 0 && mw.now().Sub(db.built()) > mw.maxDatabaseAge
+}
+
+// loadMetadata reads the metadata of the databases, logs it, and checks that they are fresh.
+// Databases with unreadable metadata are logged and ignored.
+func (mw *TraefikGeoIP) loadMetadata(paths ...string) error {
+	databases := []database{}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+
+		metadata, err := ReadMetadata(path)
+		if err != nil {
+			log.Printf("[geoip] unable to read database metadata: path=%s, name=%s, err=%v", path, mw.name, err)
+			continue
+		}
+
+		db := database{path: path, metadata: metadata}
+		log.Printf("[geoip] database loaded: path=%s, type=%s, build=%s, ipVersion=%d, languages=%v, description=%s, name=%s",
+			path, metadata.DatabaseType, db.built().Format(time.RFC3339), metadata.IPVersion, metadata.Languages,
+			metadata.Description["en"], mw.name)
+
+		if mw.isStale(db) {
+			if mw.onStaleDatabase == StaleDatabaseFail {
+				return fmt.Errorf("stale database: path=%s, build=%s, maxDatabaseAge=%s", path, db.built().Format(time.RFC3339), mw.maxDatabaseAge)
+			}
+			log.Printf("[geoip] stale database: path=%s, build=%s, maxDatabaseAge=%s, name=%s",
+				path, db.built().Format(time.RFC3339), mw.maxDatabaseAge, mw.name)
+		}
+
+		databases = append(databases, db)
+	}
+
+	mw.databasesMu.Lock()
+	defer mw.databasesMu.Unlock()
+	mw.databases = databases
+	if mw.dbBuildHeader && len(databases) > 0 {
+		mw.dbBuild = databases[0].built().Format(time.RFC3339)
+	}
+	return nil
+}
+
+// databaseBuild returns the value of the DBBuildHeader, or an empty string.
+func (mw *TraefikGeoIP) databaseBuild() string {
+	mw.databasesMu.RLock()
+	defer mw.databasesMu.RUnlock()
+	return mw.dbBuild
+}
+
+// serveHealth answers the health endpoint with the status, and the databases' metadata and the metrics to trusted callers.
+// A stale database makes the middleware degraded, unless stale databases only warn. So does a missing database.
+func (mw *TraefikGeoIP) serveHealth(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		rw.Header().Set("Allow", "GET, HEAD")
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	mw.databasesMu.RLock()
+	databases := mw.databases
+	mw.databasesMu.RUnlock()
+
+	answer := health{Status: HealthOK, Databases: []databaseHealth{}, Metrics: mw.Metrics()}
+	if mw.databaseMissing() {
+		answer.Status = HealthDegraded
+	}
+	for _, db := range databases {
+		stale := mw.isStale(db)
+		if stale && mw.onStaleDatabase != StaleDatabaseWarn {
+			answer.Status = HealthDegraded
+		}
+		answer.Databases = append(answer.Databases, databaseHealth{
+			Path:         db.path,
+			DatabaseType: db.metadata.DatabaseType,
+			BuildEpoch:   db.metadata.BuildEpoch,
+			Build:        db.built().Format(time.RFC3339),
+			IPVersion:    db.metadata.IPVersion,
+			Languages:    db.metadata.Languages,
+			Description:  db.metadata.Description,
+			Stale:        stale,
+		})
+	}
+
+	// Database paths and decision counters are not for everyone who can reach the path.
+	var payload interface{} = answer
+	if !fromTrustedAddr(req, mw.healthTrusted) {
+		payload = healthStatus{Status: answer.Status}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		if mw.debug {
+			log.Printf("[geoip] unable to encode health answer: name=%s, err=%v", mw.name, err)
+		}
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if answer.Status != HealthOK {
+		status = http.StatusServiceUnavailable
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(status)
+	if req.Method != http.MethodHead {
+		_, _ = rw.Write(body)
+	}
+}
@@ -0,0 +1,91 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHealthEndpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	if err := os.WriteFile(path, testMetadata(1735689600), 0o600); err != nil {
+		t.Fatalf("Error writing %v", err)
+	}
+
+	cfg := CreateConfig()
+	cfg.HealthPath = "/geoip/health"
+	cfg.HealthTrustedIPs = []string{"188.193.88.0/24"}
+	cfg.MaxDatabaseAge = "720h"
+	cfg.OnStaleDatabase = StaleDatabaseDegrade
+	cfg.DBBuildHeader = true
+	instance := newTestMiddleware(t, cfg, munichResult())
+	now := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	instance.now = func() time.Time { return now }
+	if err := instance.loadMetadata(path, ""); err != nil {
+		t.Fatalf("Error loading metadata %v", err)
+	}
+
+	_, req := serveRequest(instance, "http://example.com/")
+	if req.Header.Get(DBBuildHeader) != "2025-01-01T00:00:00Z" {
+		t.Fatalf("invalid build header '%s'", req.Header.Get(DBBuildHeader))
+	}
+
+	serveHealth := func() health {
+		t.Helper()
+		recorder, _ := serveRequest(instance, "http://example.com/geoip/health")
+		answer := health{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &answer); err != nil {
+			t.Fatalf("invalid health answer '%s'", recorder.Body.String())
+		}
+		if (answer.Status == HealthOK) != (recorder.Code == http.StatusOK) {
+			t.Fatalf("invalid status %d for %s", recorder.Code, answer.Status)
+		}
+		return answer
+	}
+
+	answer := serveHealth()
+	if answer.Status != HealthOK || len(answer.Databases) != 1 || answer.Databases[0].DatabaseType != "GeoLite2-City" ||
+		answer.Databases[0].Stale || answer.Metrics == nil {
+		t.Fatalf("invalid health answer %+v", answer)
+	}
+
+	// Other callers only get the status.
+	req = httptest.NewRequest(http.MethodGet, "http://example.com/geoip/health", nil)
+	req.RemoteAddr = "203.0.113.7:9999"
+	recorder := httptest.NewRecorder()
+	instance.ServeHTTP(recorder, req)
+	assertStatus(t, recorder, http.StatusOK)
+	if recorder.Body.String() != `{"status":"ok"}` {
+		t.Fatalf("untrusted callers must only get the status, got '%s'", recorder.Body.String())
+	}
+
+	now = now.AddDate(0, 2, 0)
+	answer = serveHealth()
+	if answer.Status != HealthDegraded || !answer.Databases[0].Stale {
+		t.Fatalf("stale database must degrade %+v", answer)
+	}
+
+	instance.onStaleDatabase = StaleDatabaseWarn
+	if answer = serveHealth(); answer.Status != HealthOK {
+		t.Fatalf("stale database must only warn %+v", answer)
+	}
+
+	instance.onStaleDatabase = StaleDatabaseFail
+	instance.databases = nil
+	if err := instance.loadMetadata(path); err == nil {
+		t.Fatalf("Must refuse to start with a stale database")
+	}
+
+	recorder = httptest.NewRecorder()
+	instance.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "http://example.com/geoip/health", nil))
+	assertStatus(t, recorder, http.StatusMethodNotAllowed)
+
+	cfg.OnStaleDatabase = "ignore"
+	if _, err := newMiddleware(nil, cfg, "traefik_geoip", staticLookup(munichResult())); err == nil {
+		t.Fatalf("Must fail on invalid stale database action")
+	}
+}
@@ -0,0 +1,411 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/IncSW/geoip2" //nolint:depguard
+)
+
+const (
+	// ip2locationHeaderSize bytes of the header read to detect and open an IP2Location BIN database.
+	ip2locationHeaderSize = 30
+	// ip2locationUnknown value of unknown strings in IP2Location databases.
+	ip2locationUnknown = "-"
+)
+
+var (
+	errIP2LocationNotFound = errors.New("ip not found in IP2Location database")
+	errInvalidIP2Location  = errors.New("invalid IP2Location database")
+)
+
+// ip2locationColumn column of each field in the records of a database type, 0 when the type does not have it.
+// Column 1 is the first IP of the range, so fields start at column 2.
+type ip2locationColumn struct {
+	columns   uint8
+	country   uint8
+	region    uint8
+	city      uint8
+	isp       uint8
+	latitude  uint8
+	longitude uint8
+}
+
+// ip2locationColumns columns of the DB1 to DB11 database types, indexed by type.
+// The domain, zip code and time zone columns of some types are counted but not read.
+var ip2locationColumns = [...]ip2locationColumn{
+	1:  {columns: 2, country: 2},
+	2:  {columns: 3, country: 2, isp: 3},
+	3:  {columns: 4, country: 2, region: 3, city: 4},
+	4:  {columns: 5, country: 2, region: 3, city: 4, isp: 5},
+	5:  {columns: 6, country: 2, region: 3, city: 4, latitude: 5, longitude: 6},
+	6:  {columns: 7, country: 2, region: 3, city: 4, latitude: 5, longitude: 6, isp: 7},
+	7:  {columns: 6, country: 2, region: 3, city: 4, isp: 5},
+	8:  {columns: 8, country: 2, region: 3, city: 4, latitude: 5, longitude: 6, isp: 7},
+	9:  {columns: 7, country: 2, region: 3, city: 4, latitude: 5, longitude: 6},
+	10: {columns: 9, country: 2, region: 3, city: 4, latitude: 5, longitude: 6, isp: 8},
+	11: {columns: 8, country: 2, region: 3, city: 4, latitude: 5, longitude: 6},
+}
+
+// ip2locationHeader header of an IP2Location BIN database. Addresses are 1-based offsets in the file.
+type ip2locationHeader struct {
+	dbType    uint8
+	columns   uint8
+	built     time.Time
+	ipv4Count uint32
+	ipv4Addr  uint32
+	ipv6Count uint32
+	ipv6Addr  uint32
+	ipv4Index uint32
+	ipv6Index uint32
+}
+
+// parseIP2LocationHeader decodes the header, and reports whether it is a valid IP2Location header.
+// Databases released since 2021 have a product code of 1, older ones 0.
+func parseIP2LocationHeader(buffer []byte) (ip2locationHeader, bool) {
+	if len(buffer) < ip2locationHeaderSize {
+		return ip2locationHeader{}, false
+	}
+
+	year, month, day, productCode := int(buffer[2]), int(buffer[3]), int(buffer[4]), buffer[29]
+	header := ip2locationHeader{
+		dbType:    buffer[0],
+		columns:   buffer[1],
+		built:     time.Date(2000+year, time.Month(month), day, 0, 0, 0, 0, time.UTC),
+		ipv4Count: binary.LittleEndian.Uint32(buffer[5:]),
+		ipv4Addr:  binary.LittleEndian.Uint32(buffer[9:]),
+		ipv6Count: binary.LittleEndian.Uint32(buffer[13:]),
+		ipv6Addr:  binary.LittleEndian.Uint32(buffer[17:]),
+		ipv4Index: binary.LittleEndian.Uint32(buffer[21:]),
+		ipv6Index: binary.LittleEndian.Uint32(buffer[25:]),
+	}
+
+	valid := (productCode == 1 || (productCode == 0 && year <= 20)) &&
+		header.dbType > 0 && header.columns >= 2 && month >= 1 && month <= 12 && day >= 1 && day <= 31 &&
+		(header.ipv4Count > 0 || header.ipv6Count > 0)
+	return header, valid
+}
+
+// readSignature reads the start and the end of a database file, which tell its format.
+func readSignature(path string) ([]byte, []byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w", err)
+	}
+
+	header := make([]byte, ip2locationHeaderSize)
+	n, err := file.ReadAt(header, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf("%w", err)
+	}
+
+	start := info.Size() - maxMetadataSize
+	if start < 0 {
+		start = 0
+	}
+	tail := make([]byte, info.Size()-start)
+	if _, err := file.ReadAt(tail, start); err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf("%w", err)
+	}
+
+	return header[:n], tail, nil
+}
+
+// isIP2Location checks the signature of a database: MaxMind databases end with their metadata marker,
+// IP2Location ones start with a header describing them.
+func isIP2Location(header, tail []byte) bool {
+	if bytes.Contains(tail, metadataStartMarker) {
+		return false
+	}
+	_, ok := parseIP2LocationHeader(header)
+	return ok
+}
+
+// ip2locationMetadata describes an IP2Location database like the metadata of a MaxMind one.
+func ip2locationMetadata(buffer []byte) (*geoip2.Metadata, error) {
+	header, ok := parseIP2LocationHeader(buffer)
+	if !ok {
+		return nil, errInvalidIP2Location
+	}
+
+	metadata := &geoip2.Metadata{
+		DatabaseType: "IP2Location-DB" + strconv.Itoa(int(header.dbType)),
+		BuildEpoch:   uint64(header.built.Unix()),
+		IPVersion:    4,
+		Languages:    []string{"en"},
+		Description:  map[string]string{"en": "IP2Location DB" + strconv.Itoa(int(header.dbType))},
+	}
+	if header.ipv6Count > 0 {
+		metadata.IPVersion = 6
+	}
+	return metadata, nil
+}
+
+// ip2locationRecord fields of an IP2Location record. Unknown strings are "-", or empty when the type does not have them.
+type ip2locationRecord struct {
+	countryCode    string
+	country        string
+	region         string
+	city           string
+	isp            string
+	hasCoordinates bool
+	latitude       float64
+	longitude      float64
+}
+
+// ip2locationReader reads IP2Location BIN databases of the DB1 to DB11 types.
+type ip2locationReader struct {
+	buffer []byte
+	header ip2locationHeader
+	column ip2locationColumn
+}
+
+// newIP2LocationReader creates a reader of the database in buffer.
+func newIP2LocationReader(buffer []byte) (*ip2locationReader, error) {
+	header, ok := parseIP2LocationHeader(buffer)
+	if !ok {
+		return nil, errInvalidIP2Location
+	}
+	if int(header.dbType) >= len(ip2locationColumns) {
+		return nil, fmt.Errorf("%w: unsupported database type DB%d", errInvalidIP2Location, header.dbType)
+	}
+
+	column := ip2locationColumns[header.dbType]
+	if header.columns != column.columns {
+		return nil, fmt.Errorf("%w: DB%d has %d columns, expected %d", errInvalidIP2Location, header.dbType, header.columns, column.columns)
+	}
+
+	return &ip2locationReader{buffer: buffer, header: header, column: column}, nil
+}
+
+// newIP2LocationReaderFromFile creates a reader of the database file.
+func newIP2LocationReaderFromFile(path string) (*ip2locationReader, error) {
+	buffer, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return newIP2LocationReader(buffer)
+}
+
+// uint32At reads the little-endian integer at the 1-based address.
+func (r *ip2locationReader) uint32At(address uint32) (uint32, error) {
+	if address == 0 || uint64(address)+3 > uint64(len(r.buffer)) {
+		return 0, fmt.Errorf("%w: address out of range", errInvalidIP2Location)
+	}
+	return binary.LittleEndian.Uint32(r.buffer[address-1:]), nil
+}
+
+// ipAt reads the IP at the 1-based address, big-endian like net.IP.
+func (r *ip2locationReader) ipAt(address uint32, size int) ([]byte, error) {
+	if address == 0 || uint64(address)-1+uint64(size) > uint64(len(r.buffer)) {
+		return nil, fmt.Errorf("%w: address out of range", errInvalidIP2Location)
+	}
+
+	ip := make([]byte, size)
+	for i := 0; i < size; i++ {
+		ip[i] = r.buffer[int(address)-1+size-1-i]
+	}
+	return ip, nil
+}
+
+// stringAt reads the string at the 0-based offset, which starts with its length.
+func (r *ip2locationReader) stringAt(offset uint32) (string, error) {
+	if uint64(offset) >= uint64(len(r.buffer)) {
+		return "", fmt.Errorf("%w: string out of range", errInvalidIP2Location)
+	}
+	end := uint64(offset) + 1 + uint64(r.buffer[offset])
+	if end > uint64(len(r.buffer)) {
+		return "", fmt.Errorf("%w: string out of range", errInvalidIP2Location)
+	}
+	return string(r.buffer[offset+1 : end]), nil
+}
+
+// Lookup finds the record of the range containing the IP.
+func (r *ip2locationReader) Lookup(ip net.IP) (*ip2locationRecord, error) {
+	count, base, index := r.header.ipv4Count, r.header.ipv4Addr, r.header.ipv4Index
+	address := ip.To4()
+	if address == nil {
+		count, base, index = r.header.ipv6Count, r.header.ipv6Addr, r.header.ipv6Index
+		address = ip.To16()
+	}
+	if address == nil || count == 0 {
+		return nil, errIP2LocationNotFound
+	}
+
+	// Ranges exclude their last IP, so the highest IP is looked up as the one before it.
+	if bytes.Equal(address, bytes.Repeat([]byte{0xff}, len(address))) {
+		address = append([]byte{}, address...)
+		address[len(address)-1]--
+	}
+
+	ipSize := len(address)
+	rowSize := uint32(ipSize) + uint32(r.header.columns-1)*4
+
+	// The index narrows the search to the rows of the IP's first 16 bits.
+	low, high := uint32(0), count
+	if index > 0 {
+		position := index + (uint32(address[0])<<8|uint32(address[1]))*8
+		var err error
+		if low, err = r.uint32At(position); err != nil {
+			return nil, err
+		}
+		if high, err = r.uint32At(position + 4); err != nil {
+			return nil, err
+		}
+	}
+
+	for low <= high {
+		mid := low + (high-low)/2
+		row := base + mid*rowSize
+		from, err := r.ipAt(row, ipSize)
+		if err != nil {
+			return nil, err
+		}
+		to, err := r.ipAt(row+rowSize, ipSize)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case bytes.Compare(address, from) < 0:
+			if mid == 0 {
+				return nil, errIP2LocationNotFound
+			}
+			high = mid - 1
+		case bytes.Compare(address, to) >= 0:
+			low = mid + 1
+		default:
+			return r.record(row + uint32(ipSize))
+		}
+	}
+
+	return nil, errIP2LocationNotFound
+}
+
+// record reads the fields of the row whose columns start at the 1-based address.
+func (r *ip2locationReader) record(columns uint32) (*ip2locationRecord, error) {
+	rec := &ip2locationRecord{}
+
+	// columnAt reads the value of a column, a string offset or a float's bits.
+	columnAt := func(column uint8) (uint32, error) {
+		return r.uint32At(columns + uint32(column-2)*4)
+	}
+	stringOf := func(column uint8, skip uint32) (string, error) {
+		if column == 0 {
+			return "", nil
+		}
+		offset, err := columnAt(column)
+		if err != nil {
+			return "", err
+		}
+		return r.stringAt(offset + skip)
+	}
+
+	var err error
+	// The country is the code, followed by the name.
+	if rec.countryCode, err = stringOf(r.column.country, 0); err != nil {
+		return nil, err
+	}
+	if rec.country, err = stringOf(r.column.country, 3); err != nil {
+		return nil, err
+	}
+	if rec.region, err = stringOf(r.column.region, 0); err != nil {
+		return nil, err
+	}
+	if rec.city, err = stringOf(r.column.city, 0); err != nil {
+		return nil, err
+	}
+	if rec.isp, err = stringOf(r.column.isp, 0); err != nil {
+		return nil, err
+	}
+
+	if r.column.latitude != 0 && r.column.longitude != 0 {
+		latitude, err := columnAt(r.column.latitude)
+		if err != nil {
+			return nil, err
+		}
+		longitude, err := columnAt(r.column.longitude)
+		if err != nil {
+			return nil, err
+		}
+		rec.hasCoordinates = true
+		// Coordinates are stored as float32, keep the precision they were written with.
+		rec.latitude, _ = strconv.ParseFloat(strconv.FormatFloat(float64(math.Float32frombits(latitude)), 'f', -1, 32), 64)
+		rec.longitude, _ = strconv.ParseFloat(strconv.FormatFloat(float64(math.Float32frombits(longitude)), 'f', -1, 32), 64)
+	}
+
+	return rec, nil
+}
+
+// ip2locationKnown checks if an IP2Location string is known.
+func ip2locationKnown(value string) bool {
+	return value != "" && value != ip2locationUnknown
+}
+
+// newIP2LocationDBLookup Create a new IP2LocationDBLookup.
+// IP2Location has region names rather than codes, and the ISP is used as the autonomous system organization.
+func newIP2LocationDBLookup(rdr *ip2locationReader) LookupGeoIP {
+	return func(ip net.IP) (*GeoIPResult, error) {
+		rec, err := rdr.Lookup(ip)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+		retval := GeoIPResult{
+			country:     Unknown,
+			countryCode: Unknown,
+			region:      Unknown,
+			city:        Unknown,
+			latitude:    Unknown,
+			longitude:   Unknown,
+			geohash:     Unknown,
+			asn:         Unknown,
+			asOrg:       Unknown,
+		}
+
+		// Unallocated ranges have an unknown country, and no meaningful coordinates.
+		if !ip2locationKnown(rec.countryCode) {
+			return &retval, nil
+		}
+		retval.countryCode = rec.countryCode
+		retval.eu = "false"
+		for _, member := range euCountries {
+			if member == rec.countryCode {
+				retval.eu = "true"
+			}
+		}
+		if ip2locationKnown(rec.country) {
+			retval.country = rec.country
+		}
+		if ip2locationKnown(rec.region) {
+			retval.region = rec.region
+		}
+		if ip2locationKnown(rec.city) {
+			retval.city = rec.city
+		}
+		if ip2locationKnown(rec.isp) {
+			retval.asOrg = rec.isp
+		}
+		if rec.hasCoordinates {
+			retval.latitude = strconv.FormatFloat(rec.latitude, 'f', -1, 64)
+			retval.longitude = strconv.FormatFloat(rec.longitude, 'f', -1, 64)
+			retval.geohash = EncodeGeoHash(rec.latitude, rec.longitude)
+			retval.lat = rec.latitude
+			retval.lng = rec.longitude
+		}
+		return &retval, nil
+	}
+}
@@ -0,0 +1,283 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type ip2locationTestRow struct {
+	from        string
+	countryCode string
+	country     string
+	region      string
+	city        string
+	isp         string
+	latitude    float32
+	longitude   float32
+}
+
+// ip2locationFixture encodes a BIN database of the given type built on 2024-10-01, with a table per IP version.
+// Each table ends with a row starting at the highest IP, closing the range of the last row.
+func ip2locationFixture(dbType uint8, ipv4, ipv6 []ip2locationTestRow) []byte {
+	const headerSize = 64
+	column := ip2locationColumns[dbType]
+	ipv4RowSize := int(column.columns) * 4
+	ipv6RowSize := 16 + int(column.columns-1)*4
+	ipv4Size := (len(ipv4) + 1) * ipv4RowSize
+	ipv6Size := 0
+	if len(ipv6) > 0 {
+		ipv6Size = (len(ipv6) + 1) * ipv6RowSize
+	}
+
+	header := make([]byte, headerSize)
+	header[0], header[1], header[2], header[3], header[4], header[29] = dbType, column.columns, 24, 10, 1, 1
+	binary.LittleEndian.PutUint32(header[5:], uint32(len(ipv4)))
+	binary.LittleEndian.PutUint32(header[9:], headerSize+1)
+	if len(ipv6) > 0 {
+		binary.LittleEndian.PutUint32(header[13:], uint32(len(ipv6)))
+		binary.LittleEndian.PutUint32(header[17:], uint32(headerSize+ipv4Size+1))
+	}
+
+	stringsBase := headerSize + ipv4Size + ipv6Size
+	strs := []byte{}
+	addString := func(value string) uint32 {
+		offset := uint32(stringsBase + len(strs))
+		strs = append(strs, byte(len(value)))
+		strs = append(strs, value...)
+		return offset
+	}
+	addCountry := func(code, name string) uint32 {
+		offset := addString(code)
+		// The name follows the code, 3 bytes after it.
+		for len(strs) < int(offset)-stringsBase+3 {
+			strs = append(strs, 0)
+		}
+		addString(name)
+		return offset
+	}
+	unknown := addString(ip2locationUnknown)
+
+	table := func(rows []ip2locationTestRow, size int) []byte {
+		buffer := []byte{}
+		last := make([]byte, size)
+		for i := range last {
+			last[i] = 0xff
+		}
+		rows = append(rows, ip2locationTestRow{})
+		for i, row := range rows {
+			from := last
+			if i < len(rows)-1 {
+				from = net.ParseIP(row.from).To16()
+				if size == 4 {
+					from = from[12:]
+				}
+			}
+			for j := size - 1; j >= 0; j-- {
+				buffer = append(buffer, from[j])
+			}
+
+			for c := uint8(2); c <= column.columns; c++ {
+				value := unknown
+				switch c {
+				case column.country:
+					value = addCountry(row.countryCode, row.country)
+				case column.region:
+					value = addString(row.region)
+				case column.city:
+					value = addString(row.city)
+				case column.isp:
+					value = addString(row.isp)
+				case column.latitude:
+					value = math.Float32bits(row.latitude)
+				case column.longitude:
+					value = math.Float32bits(row.longitude)
+				}
+				buffer = binary.LittleEndian.AppendUint32(buffer, value)
+			}
+		}
+		return buffer
+	}
+
+	buffer := append(header, table(ipv4, 4)...)
+	if len(ipv6) > 0 {
+		buffer = append(buffer, table(ipv6, 16)...)
+	}
+	return append(buffer, strs...)
+}
+
+// writeIP2LocationFixture writes the database to a file whose name does not tell its format.
+func writeIP2LocationFixture(t *testing.T, buffer []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "IP2LOCATION-LITE.BIN")
+	if err := os.WriteFile(path, buffer, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func unallocated(from string) ip2locationTestRow {
+	return ip2locationTestRow{from: from, countryCode: "-", country: "-", region: "-", city: "-", isp: "-"}
+}
+
+func TestIP2LocationLookupDB11(t *testing.T) {
+	path := writeIP2LocationFixture(t, ip2locationFixture(11, []ip2locationTestRow{
+		unallocated("0.0.0.0"),
+		{from: "81.2.69.0", countryCode: "GB", country: "United Kingdom of Great Britain and Northern Ireland", region: "England", city: "London", latitude: 51.5085, longitude: -0.1257},
+		unallocated("81.2.70.0"),
+		{from: "188.193.0.0", countryCode: "DE", country: "Germany", region: "Bayern", city: "Munich", latitude: 48.1375, longitude: 11.575},
+		unallocated("188.194.0.0"),
+	}, []ip2locationTestRow{
+		unallocated("::"),
+		{from: "2001:db8::", countryCode: "DE", country: "Germany", region: "Berlin", city: "Berlin", latitude: 52.5244, longitude: 13.4105},
+		unallocated("2001:db9::"),
+	}))
+
+	lookup, err := NewLookup(path)
+	if err != nil {
+		t.Fatalf("Error creating lookup %v", err)
+	}
+
+	result, err := lookup(net.ParseIP("188.193.88.199"))
+	if err != nil {
+		t.Fatalf("Error looking up %v", err)
+	}
+	if result.countryCode != "DE" || result.country != "Germany" || result.region != "Bayern" || result.city != "Munich" ||
+		result.eu != "true" || result.asn != Unknown || result.asOrg != Unknown {
+		t.Fatalf("invalid result %+v", result)
+	}
+	if result.latitude != "48.1375" || result.longitude != "11.575" || result.geohash != EncodeGeoHash(48.1375, 11.575) ||
+		!result.hasCoordinates() {
+		t.Fatalf("invalid coordinates %+v", result)
+	}
+
+	result, err = lookup(net.ParseIP("81.2.69.160"))
+	if err != nil || result.countryCode != "GB" || result.city != "London" || result.eu != "false" {
+		t.Fatalf("invalid result %+v, %v", result, err)
+	}
+
+	result, err = lookup(net.ParseIP("2001:db8::1"))
+	if err != nil || result.countryCode != "DE" || result.city != "Berlin" || result.latitude != "52.5244" {
+		t.Fatalf("invalid IPv6 result %+v, %v", result, err)
+	}
+
+	// Unallocated ranges, down to the highest IPs, are unknown.
+	for _, ip := range []string{"10.0.0.1", "255.255.255.255", "2001:db9::1", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"} {
+		result, err = lookup(net.ParseIP(ip))
+		if err != nil || result.countryCode != Unknown || result.region != Unknown || result.latitude != Unknown ||
+			result.hasCoordinates() || result.eu != "" {
+			t.Fatalf("expected an unknown result for %s, got %+v, %v", ip, result, err)
+		}
+	}
+}
+
+func TestIP2LocationLookupDB2(t *testing.T) {
+	path := writeIP2LocationFixture(t, ip2locationFixture(2, []ip2locationTestRow{
+		unallocated("0.0.0.0"),
+		{from: "188.193.0.0", countryCode: "DE", country: "Germany", isp: "Vodafone GmbH"},
+		unallocated("188.194.0.0"),
+	}, nil))
+
+	lookup, err := NewLookup(path)
+	if err != nil {
+		t.Fatalf("Error creating lookup %v", err)
+	}
+
+	result, err := lookup(net.ParseIP("188.193.88.199"))
+	if err != nil || result.countryCode != "DE" || result.asOrg != "Vodafone GmbH" || result.asn != Unknown ||
+		result.region != Unknown || result.city != Unknown || result.hasCoordinates() {
+		t.Fatalf("invalid result %+v, %v", result, err)
+	}
+
+	// Without an IPv6 table, IPv6 addresses are not found.
+	if _, err := lookup(net.ParseIP("2001:db8::1")); !errors.Is(err, errIP2LocationNotFound) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+}
+
+func TestIP2LocationMetadata(t *testing.T) {
+	path := writeIP2LocationFixture(t, ip2locationFixture(11, []ip2locationTestRow{unallocated("0.0.0.0")},
+		[]ip2locationTestRow{unallocated("::")}))
+
+	metadata, err := ReadMetadata(path)
+	if err != nil {
+		t.Fatalf("Error reading metadata %v", err)
+	}
+	built := time.Date(2024, time.October, 1, 0, 0, 0, 0, time.UTC)
+	if metadata.DatabaseType != "IP2Location-DB11" || metadata.BuildEpoch != uint64(built.Unix()) || metadata.IPVersion != 6 {
+		t.Fatalf("invalid metadata %+v", metadata)
+	}
+}
+
+func TestIP2LocationSignature(t *testing.T) {
+	buffer := ip2locationFixture(3, []ip2locationTestRow{unallocated("0.0.0.0")}, nil)
+	if !isIP2Location(buffer[:ip2locationHeaderSize], buffer) {
+		t.Fatal("expected an IP2Location database")
+	}
+
+	// A MaxMind database is never read as IP2Location, whatever its first bytes.
+	mmdb := append(append([]byte{}, buffer[:ip2locationHeaderSize]...), testMetadata(1)...)
+	if isIP2Location(mmdb[:ip2locationHeaderSize], mmdb) {
+		t.Fatal("expected a MaxMind database")
+	}
+
+	// Types other than DB1 to DB11, and types with the wrong columns, are not supported.
+	unsupported := append([]byte{}, buffer...)
+	unsupported[0] = 12
+	if _, err := newIP2LocationReader(unsupported); !errors.Is(err, errInvalidIP2Location) {
+		t.Fatalf("expected an unsupported type error, got %v", err)
+	}
+	invalid := append([]byte{}, buffer...)
+	invalid[1] = 5
+	if _, err := newIP2LocationReader(invalid); !errors.Is(err, errInvalidIP2Location) {
+		t.Fatalf("expected an invalid columns error, got %v", err)
+	}
+
+	// A database cut short fails lookups instead of panicking.
+	reader, err := newIP2LocationReader(buffer[:80])
+	if err != nil {
+		t.Fatalf("Error creating reader %v", err)
+	}
+	if _, err := reader.Lookup(net.ParseIP("1.1.1.1")); !errors.Is(err, errInvalidIP2Location) {
+		t.Fatalf("expected an invalid database error, got %v", err)
+	}
+}
+
+func TestIP2LocationRegionRule(t *testing.T) {
+	path := writeIP2LocationFixture(t, ip2locationFixture(3, []ip2locationTestRow{
+		unallocated("0.0.0.0"),
+		{from: "188.193.0.0", countryCode: "DE", country: "Germany", region: "Bayern", city: "Munich"},
+		unallocated("188.194.0.0"),
+	}, nil))
+	lookup, err := NewLookup(path)
+	if err != nil {
+		t.Fatalf("Error creating lookup %v", err)
+	}
+
+	// Region names are matched whatever their case, alone or with the country code.
+	for regions, expected := range map[string]int{
+		"Bayern":    http.StatusForbidden,
+		"DE-Bayern": http.StatusForbidden,
+		"de-bayern": http.StatusForbidden,
+		"BAYERN":    http.StatusForbidden,
+		"DE-Berlin": http.StatusOK,
+		"DE-BY":     http.StatusOK,
+	} {
+		cfg := CreateConfig()
+		cfg.Rules = []RuleConfig{{Name: "deny-region", Regions: []string{regions}, Action: RuleActionDeny}}
+		instance, err := newMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}), cfg, "traefik_geoip", lookup)
+		if err != nil {
+			t.Fatalf("Error creating %v", err)
+		}
+		recorder, _ := serveRequest(instance, "http://localhost")
+		if recorder.Code != expected {
+			t.Fatalf("expected %d for %s, got %d", expected, regions, recorder.Code)
+		}
+	}
+}
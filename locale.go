@@ -0,0 +1,94 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CurrencyHeader ISO 4217 currency header name.
+	CurrencyHeader = "GeoIP-Currency"
+	// LanguagesHeader country languages header name.
+	LanguagesHeader = "GeoIP-Languages"
+	// CallingCodeHeader international calling code header name.
+	CallingCodeHeader = "GeoIP-Calling-Code"
+	// LocaleHeader suggested locale header name.
+	LocaleHeader = "GeoIP-Locale"
+)
+
+// acceptedLanguages returns the primary language subtags of an Accept-Language header, by descending preference.
+func acceptedLanguages(header string) []string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+
+	entries := []weighted{}
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		tag := strings.TrimSpace(params[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		lang := strings.ToLower(strings.SplitN(tag, "-", 2)[0])
+		entries = append(entries, weighted{lang: lang, q: q})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].q > entries[j].q })
+
+	langs := make([]string, len(entries))
+	for i, entry := range entries {
+		langs[i] = entry.lang
+	}
+	return langs
+}
+
+// suggestLocale combines the client's preferred languages with the country.
+// It picks the most preferred language spoken in the country, or the country's main language otherwise.
+func suggestLocale(req *http.Request, countryCode string, info countryInfo) string {
+	if len(info.languages) == 0 {
+		return ""
+	}
+
+	lang := info.languages[0]
+	for _, accepted := range acceptedLanguages(req.Header.Get("Accept-Language")) {
+		found := false
+		for _, spoken := range info.languages {
+			if accepted == spoken {
+				lang, found = spoken, true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+
+	return lang + "-" + countryCode
+}
+
+// setLocale adds the locale data of the result's country.
+func setLocale(req *http.Request, result *GeoIPResult) {
+	info, ok := countries[strings.ToUpper(result.countryCode)]
+	if !ok {
+		return
+	}
+
+	result.currency = info.currency
+	result.languages = strings.Join(info.languages, ",")
+	result.callingCode = info.callingCode
+	result.locale = suggestLocale(req, strings.ToUpper(result.countryCode), info)
+}
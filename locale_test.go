@@ -0,0 +1,57 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAcceptedLanguages(t *testing.T) {
+	langs := acceptedLanguages("de-CH;q=0.5, fr-CA, en;q=0.8, *;q=0.1, it;q=0")
+	expected := []string{"fr", "en", "de"}
+	if len(langs) != len(expected) {
+		t.Fatalf("invalid languages %v", langs)
+	}
+	for i := range expected {
+		if langs[i] != expected[i] {
+			t.Fatalf("invalid languages %v, not %v", langs, expected)
+		}
+	}
+}
+
+func TestLocaleHeaders(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.LocaleHeaders = true
+	canada := munichResult()
+	canada.countryCode = "CA"
+	instance := newTestMiddleware(t, cfg, canada)
+
+	cases := map[string]string{
+		"fr-FR,fr;q=0.9,en;q=0.5": "fr-CA",
+		"en-US":                   "en-CA",
+		"de-DE":                   "en-CA",
+		"":                        "en-CA",
+	}
+	for acceptLanguage, expected := range cases {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+		req.RemoteAddr = "188.193.88.199:9999"
+		req.Header.Set("Accept-Language", acceptLanguage)
+		instance.ServeHTTP(httptest.NewRecorder(), req)
+
+		if req.Header.Get(LocaleHeader) != expected {
+			t.Fatalf("invalid locale for '%s': '%s', not '%s'", acceptLanguage, req.Header.Get(LocaleHeader), expected)
+		}
+		if req.Header.Get(CurrencyHeader) != "CAD" || req.Header.Get(CallingCodeHeader) != "+1" ||
+			req.Header.Get(LanguagesHeader) != "en,fr" {
+			t.Fatalf("invalid locale headers %v", req.Header)
+		}
+	}
+}
+
+func TestCountryInfoTable(t *testing.T) {
+	for code, info := range countries {
+		if len(code) != 2 || (code != "AQ" && (len(info.currency) != 3 || len(info.languages) == 0)) {
+			t.Fatalf("invalid country info for %s: %+v", code, info)
+		}
+	}
+}
@@ -26,6 +26,21 @@ const (
 	LongitudeHeader = "GeoIP-Longitude"
 	// GeohashHeader geohash header name.
 	GeohashHeader = "GeoIP-Geohash"
+	// ASNHeader autonomous system number header name.
+	ASNHeader = "GeoIP-ASN"
+	// ASOrganizationHeader autonomous system organization header name.
+	ASOrganizationHeader = "GeoIP-AS-Organization"
+	// EUHeader European Union membership header name.
+	EUHeader = "GeoIP-EU"
+	// CountrySourceHeader header naming where the country came from when it is not the physical country.
+	CountrySourceHeader = "GeoIP-Country-Source"
+	// RegisteredCountryCodeHeader registered country code header name.
+	RegisteredCountryCodeHeader = "GeoIP-Registered-Country-Code"
+	// RepresentedCountryCodeHeader represented country code header name.
+	RepresentedCountryCodeHeader = "GeoIP-Represented-Country-Code"
+
+	// CountrySourceRegistered the country is the registered country, because the physical one is unknown.
+	CountrySourceRegistered = "registered"
 )
 
 // GeoIPResult in memory, this should have between 126 and 180 bytes. On average, consider 150 bytes.
@@ -37,6 +52,121 @@ type GeoIPResult struct {
 	latitude    string
 	longitude   string
 	geohash     string
+	asn         string
+	asOrg       string
+	// eu is "true" or "false" when the country is known.
+	eu string
+	// countrySource is empty for the physical country, or CountrySourceRegistered for the fallback.
+	countrySource          string
+	registeredCountryCode  string
+	representedCountryCode string
+	// accuracyRadius in kilometers around the coordinates, 0 if unknown.
+	accuracyRadius uint16
+	// lat and lng are the coordinates as numbers, only meaningful when latitude and longitude are known.
+	lat float64
+	lng float64
+	// geofences containing the coordinates.
+	geofences []string
+	// nearest configured region and the distance to it.
+	nearest    string
+	distanceKm string
+	// anonymity traits of the IP.
+	anonymousProxy    bool
+	satelliteProvider bool
+	// groups of the country.
+	groups []string
+	// locale data derived from the country.
+	currency    string
+	languages   string
+	callingCode string
+	locale      string
+}
+
+// hasCoordinates checks if the result has a known location.
+func (r *GeoIPResult) hasCoordinates() bool {
+	return r.latitude != Unknown && r.longitude != Unknown
+}
+
+// geoField is a single value of a GeoIPResult along with its public name and header.
+type geoField struct {
+	name   string
+	header string
+	value  string
+}
+
+// allFields returns every value of the result in a stable order, including unknown ones.
+func (r *GeoIPResult) allFields() []geoField {
+	return []geoField{
+		{name: "country", header: CountryHeader, value: r.country},
+		{name: "countryCode", header: CountryCodeHeader, value: r.countryCode},
+		{name: "region", header: RegionHeader, value: r.region},
+		{name: "city", header: CityHeader, value: r.city},
+		{name: "latitude", header: LatitudeHeader, value: r.latitude},
+		{name: "longitude", header: LongitudeHeader, value: r.longitude},
+		{name: "geohash", header: GeohashHeader, value: r.geohash},
+		{name: "eu", header: EUHeader, value: r.eu},
+		{name: "countrySource", header: CountrySourceHeader, value: r.countrySource},
+		{name: "registeredCountryCode", header: RegisteredCountryCodeHeader, value: r.registeredCountryCode},
+		{name: "representedCountryCode", header: RepresentedCountryCodeHeader, value: r.representedCountryCode},
+		{name: "asn", header: ASNHeader, value: r.asn},
+		{name: "asOrganization", header: ASOrganizationHeader, value: r.asOrg},
+		{name: "groups", header: GroupsHeader, value: strings.Join(r.groups, ",")},
+		{name: "geofences", header: GeofencesHeader, value: strings.Join(r.geofences, ",")},
+		{name: "nearest", header: NearestHeader, value: r.nearest},
+		{name: "distanceKm", header: DistanceHeader, value: r.distanceKm},
+		{name: "currency", header: CurrencyHeader, value: r.currency},
+		{name: "languages", header: LanguagesHeader, value: r.languages},
+		{name: "callingCode", header: CallingCodeHeader, value: r.callingCode},
+		{name: "locale", header: LocaleHeader, value: r.locale},
+	}
+}
+
+// fields returns the known values of the result in a stable order.
+func (r *GeoIPResult) fields() []geoField {
+	all := r.allFields()
+	known := make([]geoField, 0, len(all))
+	for _, field := range all {
+		if field.value != Unknown && field.value != "" {
+			known = append(known, field)
+		}
+	}
+
+	return known
+}
+
+// setCountries sets the country, falling back to the registered country when the physical one is unknown.
+func (r *GeoIPResult) setCountries(country, registered, represented *geoip2.Country) {
+	r.registeredCountryCode = registered.ISOCode
+	r.representedCountryCode = represented.ISOCode
+
+	if country.ISOCode == "" && registered.ISOCode != "" {
+		country = registered
+		r.countrySource = CountrySourceRegistered
+	}
+	if country.ISOCode == "" {
+		return
+	}
+
+	r.countryCode = country.ISOCode
+	if name, ok := country.Names["en"]; ok {
+		r.country = name
+	}
+	r.eu = strconv.FormatBool(country.IsInEuropeanUnion)
+}
+
+// setLocation sets the coordinates of the location.
+// Records without a location decode to 0,0, which is never a real location, so it is left unknown.
+func (r *GeoIPResult) setLocation(location *geoip2.Location) {
+	if location.Latitude == 0 && location.Longitude == 0 {
+		return
+	}
+
+	r.latitude = strconv.FormatFloat(location.Latitude, 'f', -1, 64)
+	r.longitude = strconv.FormatFloat(location.Longitude, 'f', -1, 64)
+	r.geohash = EncodeGeoHash(location.Latitude, location.Longitude)
+	r.accuracyRadius = location.AccuracyRadius
+	r.lat = location.Latitude
+	r.lng = location.Longitude
 }
 
 // LookupGeoIP LookupGeoIP.
@@ -51,22 +181,30 @@ func newCityDBLookup(rdr *geoip2.CityReader) LookupGeoIP {
 		}
 		retval := GeoIPResult{
 			country:     Unknown,
-			countryCode: rec.Country.ISOCode,
+			countryCode: Unknown,
 			region:      Unknown,
 			city:        Unknown,
-			latitude:    strconv.FormatFloat(rec.Location.Latitude, 'f', -1, 64),
-			longitude:   strconv.FormatFloat(rec.Location.Longitude, 'f', -1, 64),
-			geohash:     EncodeGeoHash(rec.Location.Latitude, rec.Location.Longitude),
-		}
-		if country, ok := rec.Country.Names["en"]; ok {
-			retval.country = country
+			latitude:    Unknown,
+			longitude:   Unknown,
+			geohash:     Unknown,
+			asn:         Unknown,
+			asOrg:       Unknown,
 		}
+		retval.setLocation(&rec.Location)
+		retval.setCountries(&rec.Country, &rec.RegisteredCountry, &rec.RepresentedCountry)
+		retval.anonymousProxy = rec.Traits.IsAnonymousProxy
+		retval.satelliteProvider = rec.Traits.IsSatelliteProvider
 		if city, ok := rec.City.Names["en"]; ok {
 			retval.city = city
 		}
 		if rec.Subdivisions != nil {
 			retval.region = rec.Subdivisions[0].ISOCode
 		}
+		// Enterprise databases include the autonomous system.
+		if rec.Traits.AutonomousSystemNumber != 0 {
+			retval.asn = strconv.FormatUint(uint64(rec.Traits.AutonomousSystemNumber), 10)
+			retval.asOrg = rec.Traits.AutonomousSystemOrganization
+		}
 		return &retval, nil
 	}
 }
@@ -80,24 +218,35 @@ func newCountryDBLookup(rdr *geoip2.CountryReader) LookupGeoIP {
 		}
 		retval := GeoIPResult{
 			country:     Unknown,
-			countryCode: rec.Country.ISOCode,
+			countryCode: Unknown,
 			region:      Unknown,
 			city:        Unknown,
 			latitude:    Unknown,
 			longitude:   Unknown,
 			geohash:     Unknown,
+			asn:         Unknown,
+			asOrg:       Unknown,
 		}
-		if country, ok := rec.Country.Names["en"]; ok {
-			retval.country = country
-		}
+		retval.setCountries(&rec.Country, &rec.RegisteredCountry, &rec.RepresentedCountry)
+		retval.anonymousProxy = rec.Traits.IsAnonymousProxy
+		retval.satelliteProvider = rec.Traits.IsSatelliteProvider
 		return &retval, nil
 	}
 }
 
 // NewLookup Create a new Lookup.
+// IP2Location BIN databases are detected by their signature, MaxMind ones by their file name.
 func NewLookup(dbPath string) (LookupGeoIP, error) {
 	var lookup LookupGeoIP
 
+	if header, tail, err := readSignature(dbPath); err == nil && isIP2Location(header, tail) {
+		rdr, err := newIP2LocationReaderFromFile(dbPath)
+		if err != nil {
+			return nil, err
+		}
+		return newIP2LocationDBLookup(rdr), nil
+	}
+
 	switch {
 	case strings.Contains(dbPath, "City"):
 		rdr, err := geoip2.NewCityReaderFromFile(dbPath)
@@ -119,3 +268,32 @@ func NewLookup(dbPath string) (LookupGeoIP, error) {
 
 	return lookup, nil
 }
+
+// withASNLookup adds the autonomous system from an ASN database to the results of the lookup.
+func withASNLookup(lookup LookupGeoIP, rdr *geoip2.ASNReader) LookupGeoIP {
+	return func(ip net.IP) (*GeoIPResult, error) {
+		retval, err := lookup(ip)
+		if err != nil {
+			return nil, err
+		}
+		rec, err := rdr.Lookup(ip)
+		if err != nil {
+			// The IP may be missing from the ASN database only, keep the geo data.
+			return retval, nil //nolint:nilerr
+		}
+		if rec.AutonomousSystemNumber != 0 {
+			retval.asn = strconv.FormatUint(uint64(rec.AutonomousSystemNumber), 10)
+			retval.asOrg = rec.AutonomousSystemOrganization
+		}
+		return retval, nil
+	}
+}
+
+// NewASNLookup Wraps a Lookup with an ASN database.
+func NewASNLookup(lookup LookupGeoIP, asnDBPath string) (LookupGeoIP, error) {
+	rdr, err := geoip2.NewASNReaderFromFile(asnDBPath)
+	if err != nil {
+		return nil, err
+	}
+	return withASNLookup(lookup, rdr), nil
+}
@@ -0,0 +1,55 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"testing"
+
+	"github.com/IncSW/geoip2" //nolint:depguard
+)
+
+func TestSetCountries(t *testing.T) {
+	result := &GeoIPResult{country: Unknown, countryCode: Unknown}
+	result.setCountries(
+		&geoip2.Country{ISOCode: "DE", Names: map[string]string{"en": "Germany"}, IsInEuropeanUnion: true},
+		&geoip2.Country{ISOCode: "US"},
+		&geoip2.Country{ISOCode: "US", Type: "military"},
+	)
+	if result.countryCode != "DE" || result.country != "Germany" || result.eu != "true" || result.countrySource != "" {
+		t.Fatalf("invalid country %+v", result)
+	}
+	if result.registeredCountryCode != "US" || result.representedCountryCode != "US" {
+		t.Fatalf("invalid registered or represented country %+v", result)
+	}
+
+	// Without a physical country, the registered one is used and flagged.
+	result = &GeoIPResult{country: Unknown, countryCode: Unknown}
+	result.setCountries(
+		&geoip2.Country{},
+		&geoip2.Country{ISOCode: "NL", Names: map[string]string{"en": "Netherlands"}, IsInEuropeanUnion: true},
+		&geoip2.Country{},
+	)
+	if result.countryCode != "NL" || result.country != "Netherlands" || result.eu != "true" ||
+		result.countrySource != CountrySourceRegistered {
+		t.Fatalf("invalid fallback country %+v", result)
+	}
+
+	result = &GeoIPResult{country: Unknown, countryCode: Unknown}
+	result.setCountries(&geoip2.Country{}, &geoip2.Country{}, &geoip2.Country{})
+	if result.countryCode != Unknown || result.eu != "" {
+		t.Fatalf("unknown country must stay unknown %+v", result)
+	}
+}
+
+func TestSetLocation(t *testing.T) {
+	result := &GeoIPResult{latitude: Unknown, longitude: Unknown, geohash: Unknown}
+	result.setLocation(&geoip2.Location{Latitude: 48.1663, Longitude: 11.5683, AccuracyRadius: 5})
+	if !result.hasCoordinates() || result.latitude != "48.1663" || result.geohash != "u284p0rv0cje" || result.accuracyRadius != 5 {
+		t.Fatalf("invalid location %+v", result)
+	}
+
+	// Records without a location are not placed at 0,0.
+	result = &GeoIPResult{latitude: Unknown, longitude: Unknown, geohash: Unknown}
+	result.setLocation(&geoip2.Location{})
+	if result.hasCoordinates() || result.geohash != Unknown {
+		t.Fatalf("missing location must stay unknown %+v", result)
+	}
+}
@@ -0,0 +1,227 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/IncSW/geoip2" //nolint:depguard
+)
+
+const (
+	// maxMetadataSize the metadata section is at most 128KiB, at the end of the database.
+	maxMetadataSize = 128 * 1024
+
+	// Types of the MaxMind DB data section used by the metadata.
+	mmdbTypeExtended = 0
+	mmdbTypeString   = 2
+	mmdbTypeFloat64  = 3
+	mmdbTypeBytes    = 4
+	mmdbTypeUint16   = 5
+	mmdbTypeUint32   = 6
+	mmdbTypeMap      = 7
+	mmdbTypeInt32    = 8
+	mmdbTypeUint64   = 9
+	mmdbTypeUint128  = 10
+	mmdbTypeArray    = 11
+	mmdbTypeBool     = 14
+	mmdbTypeFloat32  = 15
+)
+
+var (
+	metadataStartMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+	errInvalidMetadata = errors.New("invalid database metadata")
+)
+
+// ReadMetadata reads the metadata of a MaxMind database.
+// The geoip2 readers do not expose the metadata, so it is decoded from the end of the file.
+// The metadata of IP2Location databases is built from their header.
+func ReadMetadata(path string) (*geoip2.Metadata, error) {
+	header, tail, err := readSignature(path)
+	if err != nil {
+		return nil, err
+	}
+	if isIP2Location(header, tail) {
+		return ip2locationMetadata(header)
+	}
+
+	return parseMetadata(tail)
+}
+
+// parseMetadata decodes the metadata map following the last metadata marker.
+func parseMetadata(buffer []byte) (*geoip2.Metadata, error) {
+	index := bytes.LastIndex(buffer, metadataStartMarker)
+	if index < 0 {
+		return nil, fmt.Errorf("%w: marker not found", errInvalidMetadata)
+	}
+
+	value, _, err := decodeMMDBValue(buffer, uint(index+len(metadataStartMarker)), 0)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: not a map", errInvalidMetadata)
+	}
+
+	metadata := &geoip2.Metadata{Description: map[string]string{}}
+	metadata.NodeCount = uint32(mmdbUint(values["node_count"]))
+	metadata.RecordSize = uint16(mmdbUint(values["record_size"]))
+	metadata.IPVersion = uint16(mmdbUint(values["ip_version"]))
+	metadata.BinaryFormatMajorVersion = uint16(mmdbUint(values["binary_format_major_version"]))
+	metadata.BinaryFormatMinorVersion = uint16(mmdbUint(values["binary_format_minor_version"]))
+	metadata.BuildEpoch = mmdbUint(values["build_epoch"])
+	metadata.DatabaseType, _ = values["database_type"].(string)
+	if languages, ok := values["languages"].([]interface{}); ok {
+		for _, language := range languages {
+			if s, ok := language.(string); ok {
+				metadata.Languages = append(metadata.Languages, s)
+			}
+		}
+	}
+	if description, ok := values["description"].(map[string]interface{}); ok {
+		for language, text := range description {
+			if s, ok := text.(string); ok {
+				metadata.Description[language] = s
+			}
+		}
+	}
+
+	if metadata.DatabaseType == "" || metadata.BuildEpoch == 0 {
+		return nil, fmt.Errorf("%w: missing database type or build epoch", errInvalidMetadata)
+	}
+	return metadata, nil
+}
+
+// mmdbUint converts a decoded unsigned integer.
+func mmdbUint(value interface{}) uint64 {
+	if v, ok := value.(uint64); ok {
+		return v
+	}
+	return 0
+}
+
+// decodeMMDBValue decodes the value at offset, and returns it with the offset following it.
+// Pointers are not supported, as the metadata does not use them.
+func decodeMMDBValue(buffer []byte, offset uint, depth int) (interface{}, uint, error) {
+	if depth > 32 {
+		return nil, 0, fmt.Errorf("%w: too deep", errInvalidMetadata)
+	}
+	if offset >= uint(len(buffer)) {
+		return nil, 0, fmt.Errorf("%w: unexpected end", errInvalidMetadata)
+	}
+
+	control := buffer[offset]
+	offset++
+	dataType := control >> 5
+	if dataType == mmdbTypeExtended {
+		if offset >= uint(len(buffer)) {
+			return nil, 0, fmt.Errorf("%w: unexpected end", errInvalidMetadata)
+		}
+		dataType = buffer[offset] + 7
+		offset++
+	}
+
+	size := uint(control & 0x1f)
+	if size >= 29 {
+		extra := size - 28
+		if offset+extra > uint(len(buffer)) {
+			return nil, 0, fmt.Errorf("%w: unexpected end", errInvalidMetadata)
+		}
+		size = uint(mmdbBytesToUint(buffer[offset : offset+extra]))
+		switch extra {
+		case 1:
+			size += 29
+		case 2:
+			size += 285
+		default:
+			size += 65821
+		}
+		offset += extra
+	}
+
+	switch dataType {
+	case mmdbTypeMap:
+		values := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := decodeMMDBValue(buffer, offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("%w: map key is not a string", errInvalidMetadata)
+			}
+			value, next, err := decodeMMDBValue(buffer, next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			values[name] = value
+			offset = next
+		}
+		return values, offset, nil
+	case mmdbTypeArray:
+		values := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := decodeMMDBValue(buffer, offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			values = append(values, value)
+			offset = next
+		}
+		return values, offset, nil
+	case mmdbTypeBool:
+		return size != 0, offset, nil
+	}
+
+	end := offset + size
+	if end > uint(len(buffer)) {
+		return nil, 0, fmt.Errorf("%w: unexpected end", errInvalidMetadata)
+	}
+	data := buffer[offset:end]
+
+	switch dataType {
+	case mmdbTypeString:
+		return string(data), end, nil
+	case mmdbTypeBytes:
+		return append([]byte{}, data...), end, nil
+	case mmdbTypeUint16, mmdbTypeUint32, mmdbTypeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("%w: integer too large", errInvalidMetadata)
+		}
+		return mmdbBytesToUint(data), end, nil
+	case mmdbTypeUint128:
+		// Not used by the metadata, kept as raw bytes.
+		return append([]byte{}, data...), end, nil
+	case mmdbTypeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("%w: integer too large", errInvalidMetadata)
+		}
+		return int32(uint32(mmdbBytesToUint(data))), end, nil
+	case mmdbTypeFloat64:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("%w: invalid double size", errInvalidMetadata)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), end, nil
+	case mmdbTypeFloat32:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("%w: invalid float size", errInvalidMetadata)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data))), end, nil
+	default:
+		return nil, 0, fmt.Errorf("%w: unsupported type %d", errInvalidMetadata, dataType)
+	}
+}
+
+// mmdbBytesToUint decodes a big-endian unsigned integer of up to 8 bytes.
+func mmdbBytesToUint(data []byte) uint64 {
+	value := uint64(0)
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
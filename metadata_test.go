@@ -0,0 +1,91 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// mmdbControl encodes the control byte of a value of the MaxMind DB data section.
+func mmdbControl(dataType byte, size int) []byte {
+	var control []byte
+	if dataType > 7 {
+		control = []byte{0, dataType - 7}
+	} else {
+		control = []byte{dataType << 5}
+	}
+	if size < 29 {
+		control[0] |= byte(size)
+		return control
+	}
+	control[0] |= 29
+	return append(control, byte(size-29))
+}
+
+func mmdbString(value string) []byte {
+	return append(mmdbControl(mmdbTypeString, len(value)), value...)
+}
+
+func mmdbUintValue(dataType byte, value uint64) []byte {
+	data := []byte{}
+	for ; value > 0; value >>= 8 {
+		data = append([]byte{byte(value)}, data...)
+	}
+	return append(mmdbControl(dataType, len(data)), data...)
+}
+
+// testMetadata encodes the metadata section of a database built at the given Unix time.
+func testMetadata(buildEpoch uint64) []byte {
+	buffer := []byte("search tree and data section")
+	buffer = append(buffer, metadataStartMarker...)
+	buffer = append(buffer, mmdbControl(mmdbTypeMap, 9)...)
+	buffer = append(buffer, mmdbString("binary_format_major_version")...)
+	buffer = append(buffer, mmdbUintValue(mmdbTypeUint16, 2)...)
+	buffer = append(buffer, mmdbString("binary_format_minor_version")...)
+	buffer = append(buffer, mmdbUintValue(mmdbTypeUint16, 0)...)
+	buffer = append(buffer, mmdbString("build_epoch")...)
+	buffer = append(buffer, mmdbUintValue(mmdbTypeUint64, buildEpoch)...)
+	buffer = append(buffer, mmdbString("database_type")...)
+	buffer = append(buffer, mmdbString("GeoLite2-City")...)
+	buffer = append(buffer, mmdbString("description")...)
+	buffer = append(buffer, mmdbControl(mmdbTypeMap, 1)...)
+	buffer = append(buffer, mmdbString("en")...)
+	buffer = append(buffer, mmdbString("GeoLite2City database, with a description long enough to need an extra size byte")...)
+	buffer = append(buffer, mmdbString("ip_version")...)
+	buffer = append(buffer, mmdbUintValue(mmdbTypeUint16, 6)...)
+	buffer = append(buffer, mmdbString("languages")...)
+	buffer = append(buffer, mmdbControl(mmdbTypeArray, 2)...)
+	buffer = append(buffer, mmdbString("de")...)
+	buffer = append(buffer, mmdbString("en")...)
+	buffer = append(buffer, mmdbString("node_count")...)
+	buffer = append(buffer, mmdbUintValue(mmdbTypeUint32, 3953766)...)
+	buffer = append(buffer, mmdbString("record_size")...)
+	buffer = append(buffer, mmdbUintValue(mmdbTypeUint16, 28)...)
+	return buffer
+}
+
+func TestReadMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	if err := os.WriteFile(path, testMetadata(1735689600), 0o600); err != nil {
+		t.Fatalf("Error writing %v", err)
+	}
+
+	metadata, err := ReadMetadata(path)
+	if err != nil {
+		t.Fatalf("Error reading metadata %v", err)
+	}
+	if metadata.DatabaseType != "GeoLite2-City" || metadata.BuildEpoch != 1735689600 || metadata.IPVersion != 6 ||
+		metadata.NodeCount != 3953766 || metadata.RecordSize != 28 || metadata.BinaryFormatMajorVersion != 2 ||
+		!reflect.DeepEqual(metadata.Languages, []string{"de", "en"}) || len(metadata.Description["en"]) < 29 {
+		t.Fatalf("invalid metadata %+v", metadata)
+	}
+
+	if _, err := parseMetadata([]byte("no marker")); err == nil {
+		t.Fatalf("Must fail without marker")
+	}
+	truncated := testMetadata(1735689600)
+	if _, err := parseMetadata(truncated[:len(truncated)-5]); err == nil {
+		t.Fatalf("Must fail on truncated metadata")
+	}
+}
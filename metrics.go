@@ -0,0 +1,42 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import "sync"
+
+// metrics in-memory counters of the middleware.
+type metrics struct {
+	mu       sync.Mutex
+	counters map[string]uint64
+}
+
+// newMetrics creates empty counters.
+func newMetrics() *metrics {
+	return &metrics{counters: map[string]uint64{}}
+}
+
+// inc increments a counter.
+func (m *metrics) inc(name string) {
+	m.mu.Lock()
+	m.counters[name]++
+	m.mu.Unlock()
+}
+
+// snapshot copies the current counters.
+func (m *metrics) snapshot() map[string]uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counters := make(map[string]uint64, len(m.counters))
+	for name, value := range m.counters {
+		counters[name] = value
+	}
+	return counters
+}
+
+// Metrics returns a snapshot of the middleware's counters.
+// Blocked requests are counted as "<action>;rule=<rule>", and requests that would have been blocked in report mode
+// as "would-<action>;rule=<rule>". Requests let through by a bypass token are counted as "bypass-<action>;rule=<rule>".
+// Simulated requests are counted as "simulated", and their decisions carry a ";simulated" suffix.
+// Audit entries dropped because the audit queue was full are counted as "auditDropped".
+func (mw *TraefikGeoIP) Metrics() map[string]uint64 {
+	return mw.metrics.snapshot()
+}
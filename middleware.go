@@ -3,11 +3,15 @@ package traefik_geoip //nolint:revive,stylecheck
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log"
 	"net"
 	"net/http"
-	"os"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -17,14 +21,67 @@ const (
 	defaultDebug = false
 	// defaultSetRealIP default set real IP.
 	defaultSetRealIP = false
+	// defaultGeohashPrecision default number of geohash characters.
+	defaultGeohashPrecision = 12
 )
 
 // Config the plugin configuration.
 type Config struct {
-	DBPath     string   `json:"dbPath,omitempty"`
-	Debug      bool     `json:"debug,omitempty"`
-	ExcludeIPs []string `json:"excludeIPs,omitempty"`
-	SetRealIP  bool     `json:"setRealIP,omitempty"` //nolint:tagliatelle
+	DBPath     string           `json:"dbPath,omitempty"`
+	ASNDBPath  string           `json:"asnDBPath,omitempty"` //nolint:tagliatelle
+	Debug      bool             `json:"debug,omitempty"`
+	ExcludeIPs []string         `json:"excludeIPs,omitempty"`
+	SetRealIP  bool             `json:"setRealIP,omitempty"` //nolint:tagliatelle
+	Whoami     WhoamiConfig     `json:"whoami,omitempty"`
+	DataHeader DataHeaderConfig `json:"dataHeader,omitempty"`
+
+	GeohashPrecision    int  `json:"geohashPrecision,omitempty"`
+	GeohashFromAccuracy bool `json:"geohashFromAccuracy,omitempty"`
+
+	Geofences      []GeofenceConfig `json:"geofences,omitempty"`
+	AllowGeofences []string         `json:"allowGeofences,omitempty"`
+	DenyGeofences  []string         `json:"denyGeofences,omitempty"`
+
+	Regions        []RegionConfig    `json:"regions,omitempty"`
+	RegionFallback map[string]string `json:"regionFallback,omitempty"`
+
+	Redirects            map[string]string `json:"redirects,omitempty"`
+	RedirectStatusCode   int               `json:"redirectStatusCode,omitempty"`
+	RedirectOptOutCookie string            `json:"redirectOptOutCookie,omitempty"`
+	RedirectOptOutQuery  string            `json:"redirectOptOutQuery,omitempty"`
+	RedirectRewrite      bool              `json:"redirectRewrite,omitempty"`
+
+	RateLimit RateLimitConfig `json:"rateLimit,omitempty"`
+
+	LocaleHeaders bool `json:"localeHeaders,omitempty"`
+
+	CountryGroups map[string][]string `json:"countryGroups,omitempty"`
+	GroupsHeader  bool                `json:"groupsHeader,omitempty"`
+
+	Rules []RuleConfig `json:"rules,omitempty"`
+
+	Mode string `json:"mode,omitempty"`
+
+	Bypass BypassConfig `json:"bypass,omitempty"`
+
+	SimulateIPHeader   string   `json:"simulateIPHeader,omitempty"`
+	SimulateTrustedIPs []string `json:"simulateTrustedIPs,omitempty"` //nolint:tagliatelle
+
+	Signature SignatureConfig `json:"signature,omitempty"`
+
+	Travel TravelConfig `json:"travel,omitempty"`
+
+	Audit AuditConfig `json:"audit,omitempty"`
+
+	HealthPath       string   `json:"healthPath,omitempty"`
+	HealthTrustedIPs []string `json:"healthTrustedIPs,omitempty"` //nolint:tagliatelle
+	MaxDatabaseAge   string   `json:"maxDatabaseAge,omitempty"`
+	OnStaleDatabase  string   `json:"onStaleDatabase,omitempty"`
+	DBBuildHeader    bool     `json:"dbBuildHeader,omitempty"` //nolint:tagliatelle
+
+	OnMissingDatabase string `json:"onMissingDatabase,omitempty"`
+
+	Chain ChainConfig `json:"chain,omitempty"`
 }
 
 // CreateConfig creates the default plugin configuration.
@@ -34,6 +91,16 @@ func CreateConfig() *Config {
 		Debug:      defaultDebug,
 		ExcludeIPs: []string{},
 		SetRealIP:  defaultSetRealIP,
+		Whoami:     WhoamiConfig{CacheControl: defaultWhoamiCacheControl},
+
+		GeohashPrecision: defaultGeohashPrecision,
+
+		RedirectStatusCode: defaultRedirectStatusCode,
+
+		Mode: ModeEnforce,
+
+		OnStaleDatabase:   StaleDatabaseWarn,
+		OnMissingDatabase: MissingDatabaseFail,
 	}
 }
 
@@ -45,28 +112,226 @@ type TraefikGeoIP struct {
 	lookup     LookupGeoIP
 	debug      bool
 	setRealIP  bool
+	whoami     *whoamiEndpoint
+	dataHeader *dataHeader
+
+	geohashPrecision    uint
+	geohashFromAccuracy bool
+
+	geofences      *geofenceIndex
+	allowGeofences map[string]bool
+	denyGeofences  map[string]bool
+	nearest        *nearestRegion
+	redirects      *redirector
+	rateLimiter    *rateLimiter
+	localeHeaders  bool
+	groups         *countryGroups
+	groupsHeader   bool
+	rules          []*rule
+	mode           string
+	metrics        *metrics
+	bypass         *bypass
+	simulation     *simulation
+	signer         *signer
+	travel         *travelDetector
+
+	auditSink       *auditSink
+	auditTruncateIP bool
+
+	healthPath      string
+	healthTrusted   []*net.IPNet
+	databasesMu     sync.RWMutex
+	databases       []database
+	maxDatabaseAge  time.Duration
+	onStaleDatabase string
+	dbBuildHeader   bool
+	dbBuild         string
+	now             func() time.Time
+
+	pending           *pendingLookup
+	onMissingDatabase string
+
+	chain *chainLookup
 }
 
 // New created a new TraefikGeoIP plugin.
-func New(_ context.Context, next http.Handler, cfg *Config, name string) (http.Handler, error) {
+// The background database loading, if any, stops when ctx is done, such as when Traefik reloads its config.
+func New(ctx context.Context, next http.Handler, cfg *Config, name string) (http.Handler, error) {
 	debug := cfg.Debug
 
 	if debug {
 		log.Printf("[geoip] setting up plugin: config=%v", cfg)
 	}
 
-	if _, err := os.Stat(cfg.DBPath); err != nil {
+	lookup, err := openLookup(cfg)
+	if err != nil {
+		// Only a database that is not there yet is waited for, invalid ones are configuration errors.
+		if !errors.Is(err, fs.ErrNotExist) ||
+			(cfg.OnMissingDatabase != MissingDatabasePassthrough && cfg.OnMissingDatabase != MissingDatabaseDeny) {
+			return nil, err
+		}
+
+		// Start without a database, and load it in the background once it appears.
+		log.Printf("[geoip] database unavailable, retrying in the background: name=%s, onMissingDatabase=%s, err=%v",
+			name, cfg.OnMissingDatabase, err)
+		pending := newPendingLookup()
+		mw, err := newMiddleware(next, cfg, name, pending.Lookup)
+		if err != nil {
+			return nil, err
+		}
+		mw.pending = pending
+		mw.logDataVersions()
+		go mw.retryDatabase(ctx, func() (LookupGeoIP, error) { return openLookup(cfg) }, cfg.DBPath, cfg.ASNDBPath)
+		return mw, nil
+	}
+
+	mw, err := newMiddleware(next, cfg, name, lookup)
+	if err != nil {
+		return nil, err
+	}
+	mw.logDataVersions()
+
+	// Surface the databases' metadata, and refuse to start with stale databases when asked to.
+	if err := mw.loadMetadata(cfg.DBPath, cfg.ASNDBPath); err != nil {
 		return nil, err
 	}
 
-	// Initialize the lookup DB.
-	lookup, err := NewLookup(cfg.DBPath)
+	return mw, nil
+}
+
+// logDataVersions logs the versions of the built-in data tables in use.
+func (mw *TraefikGeoIP) logDataVersions() {
+	if mw.localeHeaders {
+		log.Printf("[geoip] locale data loaded: version=%s, name=%s", countryInfoVersion, mw.name)
+	}
+	log.Printf("[geoip] country groups loaded: version=%s, name=%s", countryGroupsVersion, mw.name)
+}
+
+// newMiddleware builds the middleware around an already initialized lookup.
+func newMiddleware(next http.Handler, cfg *Config, name string, lookup LookupGeoIP) (*TraefikGeoIP, error) {
+	mw, err := newLookupMiddleware(cfg, name, lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	whoami, err := newWhoamiEndpoint(cfg.Whoami)
+	if err != nil {
+		return nil, err
+	}
+
+	dataHeader, err := newDataHeader(cfg.DataHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	allowGeofences, err := geofenceSet(mw.geofences, cfg.AllowGeofences)
+	if err != nil {
+		return nil, err
+	}
+	denyGeofences, err := geofenceSet(mw.geofences, cfg.DenyGeofences)
+	if err != nil {
+		return nil, err
+	}
+
+	redirects, err := newRedirector(cfg, mw.groups)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateMode(cfg.Mode); err != nil {
+		return nil, err
+	}
+
+	rules, err := compileRules(cfg.Rules, mw.groups, mw.geofences)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimiter, err := newRateLimiter(cfg.RateLimit, mw.groups)
+	if err != nil {
+		return nil, err
+	}
+
+	simulation, err := newSimulation(cfg.SimulateIPHeader, cfg.SimulateTrustedIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	bypass := newBypass(cfg.Bypass)
+	keep := []string{cfg.SimulateIPHeader}
+	if bypass != nil {
+		keep = append(keep, bypass.tokenHeader)
+	}
+	signer, err := newSigner(cfg.Signature, keep...)
+	if err != nil {
+		return nil, err
+	}
+
+	travel, err := newTravelDetector(cfg.Travel)
+	if err != nil {
+		return nil, err
+	}
+
+	healthTrusted, err := parseCIDRs(cfg.HealthTrustedIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	maxDatabaseAge, err := validateStaleDatabase(cfg.MaxDatabaseAge, cfg.OnStaleDatabase)
 	if err != nil {
-		if debug {
-			log.Printf("[geoip] error initializing lookup: err=%v", err)
-		}
 		return nil, err
 	}
+	if err := validateMissingDatabase(cfg.OnMissingDatabase); err != nil {
+		return nil, err
+	}
+
+	chain, err := newChainLookup(cfg.Chain)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open the audit log last, nothing can fail after it.
+	auditSink, err := openAuditSink(cfg.Audit)
+	if err != nil {
+		return nil, err
+	}
+
+	mw.next = next
+	mw.setRealIP = cfg.SetRealIP
+	mw.whoami = whoami
+	mw.dataHeader = dataHeader
+
+	mw.allowGeofences = allowGeofences
+	mw.denyGeofences = denyGeofences
+	mw.redirects = redirects
+	mw.rateLimiter = rateLimiter
+	mw.rules = rules
+	mw.mode = cfg.Mode
+	mw.bypass = bypass
+	mw.simulation = simulation
+	mw.signer = signer
+	mw.travel = travel
+
+	mw.auditSink = auditSink
+	mw.auditTruncateIP = cfg.Audit.TruncateIP
+
+	mw.healthPath = cfg.HealthPath
+	mw.healthTrusted = healthTrusted
+	mw.maxDatabaseAge = maxDatabaseAge
+	mw.onStaleDatabase = cfg.OnStaleDatabase
+	mw.dbBuildHeader = cfg.DBBuildHeader
+
+	mw.onMissingDatabase = cfg.OnMissingDatabase
+
+	mw.chain = chain
+
+	return mw, nil
+}
+
+// newLookupMiddleware builds the parts of the middleware that find and look up client IPs.
+// Resolvers only need these, so they never open audit logs or build rate limiters.
+func newLookupMiddleware(cfg *Config, name string, lookup LookupGeoIP) (*TraefikGeoIP, error) {
+	debug := cfg.Debug
 
 	// Parse CIDRs and store them in a slice for exclusion.
 	excludedIPs := []*net.IPNet{}
@@ -89,13 +354,46 @@ func New(_ context.Context, next http.Handler, cfg *Config, name string) (http.H
 		excludedIPs = append(excludedIPs, excludedNet)
 	}
 
+	// Configs not built with CreateConfig leave the precision unset.
+	geohashPrecision := cfg.GeohashPrecision
+	if geohashPrecision == 0 {
+		geohashPrecision = defaultGeohashPrecision
+	}
+	if geohashPrecision < 1 || geohashPrecision > 12 {
+		return nil, fmt.Errorf("invalid geohash precision, must be between 1 and 12: precision=%d", geohashPrecision)
+	}
+
+	geofences, err := newGeofenceIndex(cfg.Geofences)
+	if err != nil {
+		return nil, err
+	}
+
+	nearest, err := newNearestRegion(cfg.Regions, cfg.RegionFallback)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := newCountryGroups(cfg.CountryGroups)
+	if err != nil {
+		return nil, err
+	}
+
 	return &TraefikGeoIP{
-		next:       next,
 		name:       name,
 		excludeIPs: excludedIPs,
 		lookup:     lookup,
 		debug:      debug,
-		setRealIP:  cfg.SetRealIP,
+
+		geohashPrecision:    uint(geohashPrecision),
+		geohashFromAccuracy: cfg.GeohashFromAccuracy,
+
+		geofences:     geofences,
+		nearest:       nearest,
+		localeHeaders: cfg.LocaleHeaders,
+		groups:        groups,
+		groupsHeader:  cfg.GroupsHeader,
+		metrics:       newMetrics(),
+		now:           time.Now,
 	}, nil
 }
 
@@ -111,6 +409,11 @@ func (mw *TraefikGeoIP) isExcluded(ip net.IP) bool {
 }
 
 func (mw *TraefikGeoIP) getClientIP(req *http.Request) net.IP {
+	// A simulated IP from a trusted client replaces the real one, and is never excluded.
+	if ip := mw.simulatedIP(req); ip != nil {
+		return ip
+	}
+
 	// Get first IP from X-Forwarded-For header if it exists.
 	ipStr := ""
 	if xff := req.Header.Get("X-Forwarded-For"); xff != "" {
@@ -147,68 +450,200 @@ func (mw *TraefikGeoIP) getClientIP(req *http.Request) net.IP {
 	return ip
 }
 
-// processRequest processes the request and adds geo headers if the IP is in the database.
-func (mw *TraefikGeoIP) processRequest(req *http.Request) *http.Request {
+// resolve finds the client IP and looks it up in the database.
+// The IP is nil if it could not be determined or is excluded, and the result is nil if the lookup failed.
+func (mw *TraefikGeoIP) resolve(req *http.Request) (net.IP, *GeoIPResult) {
 	// Get the client IP.
 	ip := mw.getClientIP(req)
 
-	// If the IP is nil, return the request unchanged.
+	// If the IP is nil, there is nothing to look up.
 	if ip == nil {
-		return req
-	}
-
-	// Set X-Real-Ip header because traefik sometimes messes with it.
-	if mw.setRealIP {
-		req.Header.Set("X-Real-Ip", ip.String())
+		return nil, nil
 	}
 
 	// Lookup the IP.
-	result, err := mw.lookup(ip)
+	result, err := mw.lookupIP(req, ip)
 	if err != nil {
 		if mw.debug {
 			log.Printf("[geoip] lookup error: ip=%v, name=%s, err=%v", ip, mw.name, err)
 		}
-		return req
+		return ip, nil
+	}
+
+	return ip, result
+}
+
+// lookupIP looks the IP up in the database, and adds the data derived from its location and country.
+func (mw *TraefikGeoIP) lookupIP(req *http.Request, ip net.IP) (*GeoIPResult, error) {
+	result, err := mw.lookup(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	mw.truncateGeohash(result)
+
+	if result.hasCoordinates() {
+		result.geofences = mw.geofences.match(result.lat, result.lng)
+	}
+	if mw.nearest != nil {
+		mw.nearest.find(result)
+	}
+	if mw.localeHeaders {
+		setLocale(req, result)
+	}
+	if mw.groupsHeader {
+		result.groups = mw.groups.of(result.countryCode)
 	}
 
 	if mw.debug {
 		log.Printf("[geoip] lookup result: ip=%v, name=%s, result=%v", ip, mw.name, result)
 	}
 
+	return result, nil
+}
+
+// truncateGeohash reduces the geohash to the configured precision.
+// A geohash prefix is the geohash of the same point with fewer characters.
+func (mw *TraefikGeoIP) truncateGeohash(result *GeoIPResult) {
+	if result.geohash == Unknown {
+		return
+	}
+
+	precision := mw.geohashPrecision
+	if mw.geohashFromAccuracy && result.accuracyRadius > 0 {
+		if fromAccuracy := GeoHashPrecisionForRadius(float64(result.accuracyRadius)); fromAccuracy < precision {
+			precision = fromAccuracy
+		}
+	}
+	if int(precision) < len(result.geohash) {
+		result.geohash = result.geohash[:precision]
+	}
+}
+
+// processRequest processes the request and adds geo headers if the IP is in the database.
+// A non-nil decision means the request must not be forwarded.
+func (mw *TraefikGeoIP) processRequest(rw http.ResponseWriter, req *http.Request) (_ *http.Request, dec *decision) {
+	ip, result := mw.resolve(req)
+	defer func() {
+		if dec != nil {
+			dec.ip, dec.result = ip, result
+		}
+	}()
+
+	// If the IP is nil, return the request unchanged.
+	if ip == nil {
+		return req, nil
+	}
+
+	// Set X-Real-Ip header because traefik sometimes messes with it.
+	if mw.setRealIP {
+		req.Header.Set("X-Real-Ip", ip.String())
+	}
+
+	// Requests with a valid bypass token are enriched, but never blocked.
+	bypassID := mw.checkBypass(req)
+
+	if dec := mw.checkMissingDatabase(); dec != nil && mw.enforce(req, dec, bypassID) {
+		return req, dec
+	}
+
+	// Apply the rules before anything else, they may skip the enrichment.
+	outcome := mw.evaluateRules(req, ip, result, bypassID)
+	if outcome.decision != nil {
+		return req, outcome.decision
+	}
+	if outcome.skip || result == nil {
+		return req, nil
+	}
+
+	// Make the result available to handlers running in the same process, like the headers.
+	typed := result.Result()
+	typed.IP = ip
+	req = req.WithContext(NewContext(req.Context(), typed))
+
 	// Set the headers.
 	setHeaders(req, result)
+	if build := mw.databaseBuild(); build != "" {
+		req.Header.Set(DBBuildHeader, build)
+	}
+	mw.setChainHeaders(req)
 
-	return req
-}
+	if mw.dataHeader != nil {
+		if err := mw.dataHeader.set(req, result); err != nil && mw.debug {
+			log.Printf("[geoip] unable to encode data header: name=%s, err=%v", mw.name, err)
+		}
+	}
 
-// ServeHTTP implements the middleware interface.
-func (mw *TraefikGeoIP) ServeHTTP(reqWr http.ResponseWriter, req *http.Request) {
-	req = mw.processRequest(req)
+	if dec := mw.checkTravel(rw, req, result, bypassID); dec != nil {
+		return req, dec
+	}
 
-	mw.next.ServeHTTP(reqWr, req)
+	if dec := mw.checkGeofences(result); dec != nil && mw.enforce(req, dec, bypassID) {
+		return req, dec
+	}
+
+	if mw.rateLimiter != nil {
+		if dec := mw.rateLimiter.check(ip, result); dec != nil && mw.enforce(req, dec, bypassID) {
+			return req, dec
+		}
+	}
+
+	if mw.redirects != nil {
+		req, dec := mw.redirects.apply(req, result)
+		if dec != nil && mw.enforce(req, dec, bypassID) {
+			return req, dec
+		}
+		return req, nil
+	}
+
+	return req, nil
 }
 
-// SetHeaders Set geo headers.
-func setHeaders(req *http.Request, result *GeoIPResult) {
-	if result.country != Unknown {
-		req.Header.Set(CountryHeader, result.country)
+// respond sends the decision to the client.
+func (mw *TraefikGeoIP) respond(rw http.ResponseWriter, req *http.Request, dec *decision) {
+	if mw.debug {
+		log.Printf("[geoip] request blocked: status=%d, action=%s, rule=%s, name=%s", dec.status, dec.action, dec.rule, mw.name)
 	}
-	if result.countryCode != Unknown {
-		req.Header.Set(CountryCodeHeader, result.countryCode)
+
+	for key, values := range dec.header {
+		rw.Header()[key] = values
 	}
-	if result.region != Unknown {
-		req.Header.Set(RegionHeader, result.region)
+	if dec.location != "" {
+		http.Redirect(rw, req, dec.location, dec.status)
+		return
 	}
-	if result.city != Unknown {
-		req.Header.Set(CityHeader, result.city)
+	http.Error(rw, http.StatusText(dec.status), dec.status)
+}
+
+// ServeHTTP implements the middleware interface.
+func (mw *TraefikGeoIP) ServeHTTP(reqWr http.ResponseWriter, req *http.Request) {
+	if mw.whoami.matches(req) {
+		mw.serveWhoami(reqWr, req)
+		return
 	}
-	if result.latitude != Unknown {
-		req.Header.Set(LatitudeHeader, result.latitude)
+	if mw.healthPath != "" && req.URL.Path == mw.healthPath {
+		mw.serveHealth(reqWr, req)
+		return
 	}
-	if result.longitude != Unknown {
-		req.Header.Set(LongitudeHeader, result.longitude)
+
+	// Only the middleware reports decisions, whether or not headers are signed.
+	req.Header.Del(PolicyDecisionHeader)
+	mw.stripGeoHeaders(req)
+	req, dec := mw.processRequest(reqWr, req)
+	markSimulated(reqWr, req)
+	if dec != nil {
+		mw.audit(req, dec)
+		mw.respond(reqWr, req, dec)
+		return
 	}
-	if result.geohash != Unknown {
-		req.Header.Set(GeohashHeader, result.geohash)
+
+	mw.sign(req)
+	mw.next.ServeHTTP(reqWr, req)
+}
+
+// SetHeaders Set geo headers.
+func setHeaders(req *http.Request, result *GeoIPResult) {
+	for _, field := range result.fields() {
+		req.Header.Set(field.header, field.value)
 	}
 }
@@ -0,0 +1,113 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// munichResult is the lookup result of a City database for a Munich IP.
+func munichResult() *GeoIPResult {
+	return &GeoIPResult{
+		country:     "Germany",
+		countryCode: "DE",
+		region:      "BY",
+		city:        "Munich",
+		latitude:    "48.1663",
+		longitude:   "11.5683",
+		geohash:     "u284p0rv0cje",
+		lat:         48.1663,
+		lng:         11.5683,
+	}
+}
+
+// staticLookup returns a lookup that resolves every IP to the given result.
+func staticLookup(result *GeoIPResult) LookupGeoIP {
+	return func(ip net.IP) (*GeoIPResult, error) {
+		copied := *result
+		return &copied, nil
+	}
+}
+
+// newTestMiddleware creates a middleware backed by a static lookup instead of a database.
+func newTestMiddleware(t *testing.T, cfg *Config, result *GeoIPResult) *TraefikGeoIP {
+	t.Helper()
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+	instance, err := newMiddleware(next, cfg, "traefik_geoip", staticLookup(result))
+	if err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+
+	return instance
+}
+
+func TestGeohashPrecision(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.GeohashPrecision = 5
+	instance := newTestMiddleware(t, cfg, munichResult())
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+	req.RemoteAddr = "188.193.88.199:9999"
+	instance.ServeHTTP(httptest.NewRecorder(), req)
+	if req.Header.Get(GeohashHeader) != "u284p" {
+		t.Fatalf("invalid geohash '%s'", req.Header.Get(GeohashHeader))
+	}
+
+	cfg = CreateConfig()
+	cfg.GeohashFromAccuracy = true
+	result := munichResult()
+	result.accuracyRadius = 100
+	instance = newTestMiddleware(t, cfg, result)
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+	req.RemoteAddr = "188.193.88.199:9999"
+	instance.ServeHTTP(httptest.NewRecorder(), req)
+	if req.Header.Get(GeohashHeader) != "u28" {
+		t.Fatalf("invalid geohash '%s'", req.Header.Get(GeohashHeader))
+	}
+
+	// An unset precision is the default one.
+	cfg = CreateConfig()
+	cfg.GeohashPrecision = 0
+	instance = newTestMiddleware(t, cfg, munichResult())
+	if instance.geohashPrecision != defaultGeohashPrecision {
+		t.Fatalf("invalid default geohash precision %d", instance.geohashPrecision)
+	}
+
+	for _, precision := range []int{-1, 13} {
+		cfg = CreateConfig()
+		cfg.GeohashPrecision = precision
+		if _, err := newMiddleware(nil, cfg, "traefik_geoip", staticLookup(result)); err == nil {
+			t.Fatalf("Must fail on invalid geohash precision %d", precision)
+		}
+	}
+}
+
+func assertStatus(t *testing.T, recorder *httptest.ResponseRecorder, expected int) {
+	t.Helper()
+	if recorder.Code != expected {
+		t.Fatalf("invalid status code %d, not %d", recorder.Code, expected)
+	}
+}
+
+// builtinGroups returns the built-in country groups.
+func builtinGroups(t *testing.T) *countryGroups {
+	t.Helper()
+
+	groups, err := newCountryGroups(nil)
+	if err != nil {
+		t.Fatalf("unable to create country groups: %v", err)
+	}
+	return groups
+}
+
+// serveRequest sends a request from a Munich IP through the middleware.
+func serveRequest(instance *TraefikGeoIP, url string) (*httptest.ResponseRecorder, *http.Request) {
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.RemoteAddr = "188.193.88.199:9999"
+	recorder := httptest.NewRecorder()
+	instance.ServeHTTP(recorder, req)
+	return recorder, req
+}
@@ -0,0 +1,160 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// MissingDatabaseFail refuses to start without a database.
+	MissingDatabaseFail = "fail"
+	// MissingDatabasePassthrough forwards requests without geo headers until the database is loaded.
+	MissingDatabasePassthrough = "passthrough"
+	// MissingDatabaseDeny answers requests with 503 until the database is loaded.
+	MissingDatabaseDeny = "deny"
+
+	// missingDatabaseRule name of the missing database policy in decisions.
+	missingDatabaseRule = "missingDatabase"
+	// defaultDatabaseRetryInitial first delay before retrying to load the database.
+	defaultDatabaseRetryInitial = time.Second
+	// defaultDatabaseRetryMax longest delay between two attempts to load the database.
+	defaultDatabaseRetryMax = time.Minute
+)
+
+var errDatabaseNotLoaded = errors.New("database not loaded")
+
+// openLookup opens the databases of the config.
+func openLookup(cfg *Config) (LookupGeoIP, error) {
+	if _, err := os.Stat(cfg.DBPath); err != nil {
+		return nil, err
+	}
+
+	// Initialize the lookup DB.
+	lookup, err := NewLookup(cfg.DBPath)
+	if err != nil {
+		if cfg.Debug {
+			log.Printf("[geoip] error initializing lookup: err=%v", err)
+		}
+		return nil, err
+	}
+
+	// Add the autonomous system from a separate database.
+	if cfg.ASNDBPath != "" {
+		lookup, err = NewASNLookup(lookup, cfg.ASNDBPath)
+		if err != nil {
+			if cfg.Debug {
+				log.Printf("[geoip] error initializing ASN lookup: err=%v", err)
+			}
+			return nil, err
+		}
+	}
+
+	return lookup, nil
+}
+
+// validateMissingDatabase checks the missing database action.
+func validateMissingDatabase(onMissing string) error {
+	switch onMissing {
+	case MissingDatabaseFail, MissingDatabasePassthrough, MissingDatabaseDeny:
+		return nil
+	default:
+		return fmt.Errorf("invalid missing database action: onMissingDatabase=%s", onMissing)
+	}
+}
+
+// pendingLookup a lookup that is set once the database is loaded in the background.
+type pendingLookup struct {
+	mu     sync.RWMutex
+	lookup LookupGeoIP
+
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+// newPendingLookup creates a lookup without a database.
+func newPendingLookup() *pendingLookup {
+	return &pendingLookup{initialBackoff: defaultDatabaseRetryInitial, maxBackoff: defaultDatabaseRetryMax}
+}
+
+// Lookup looks the IP up once the database is loaded, and fails until then.
+func (p *pendingLookup) Lookup(ip net.IP) (*GeoIPResult, error) {
+	p.mu.RLock()
+	lookup := p.lookup
+	p.mu.RUnlock()
+
+	if lookup == nil {
+		return nil, errDatabaseNotLoaded
+	}
+	return lookup(ip)
+}
+
+// loaded checks if the database is loaded.
+func (p *pendingLookup) loaded() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.lookup != nil
+}
+
+// set uses the loaded database.
+func (p *pendingLookup) set(lookup LookupGeoIP) {
+	p.mu.Lock()
+	p.lookup = lookup
+	p.mu.Unlock()
+}
+
+// databaseMissing checks if the middleware is still waiting for its database.
+func (mw *TraefikGeoIP) databaseMissing() bool {
+	return mw.pending != nil && !mw.pending.loaded()
+}
+
+// checkMissingDatabase denies requests while the database is missing, when asked to.
+func (mw *TraefikGeoIP) checkMissingDatabase() *decision {
+	if mw.onMissingDatabase != MissingDatabaseDeny || !mw.databaseMissing() {
+		return nil
+	}
+	return &decision{status: http.StatusServiceUnavailable, action: decisionDeny, rule: missingDatabaseRule}
+}
+
+// retryDatabase tries to load the database with an exponential backoff, until it succeeds or ctx is done.
+// Enrichment starts as soon as the database is loaded. The metadata of the database is checked like at startup.
+func (mw *TraefikGeoIP) retryDatabase(ctx context.Context, open func() (LookupGeoIP, error), paths ...string) {
+	p := mw.pending
+	backoff := p.initialBackoff
+	for {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if mw.debug {
+				log.Printf("[geoip] stopped waiting for the database: name=%s", mw.name)
+			}
+			return
+		case <-timer.C:
+		}
+
+		lookup, err := open()
+		if err == nil {
+			err = mw.loadMetadata(paths...)
+		}
+		if err == nil {
+			p.set(lookup)
+			log.Printf("[geoip] database loaded in the background: name=%s", mw.name)
+			return
+		}
+
+		if mw.debug {
+			log.Printf("[geoip] database still unavailable: name=%s, retry=%s, err=%v", mw.name, backoff, err)
+		}
+		backoff *= 2
+		if backoff > p.maxBackoff {
+			backoff = p.maxBackoff
+		}
+	}
+}
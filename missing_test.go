@@ -0,0 +1,119 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMissingDatabase(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.DBPath = filepath.Join(t.TempDir(), "missing.mmdb")
+	if _, err := New(context.Background(), nil, cfg, "traefik_geoip"); err == nil {
+		t.Fatalf("Must fail without database by default")
+	}
+
+	cfg.OnMissingDatabase = MissingDatabasePassthrough
+	handler, err := New(context.Background(), http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}), cfg, "traefik_geoip")
+	if err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+	recorder, req := serveRequest(handler.(*TraefikGeoIP), "http://example.com/")
+	assertStatus(t, recorder, http.StatusOK)
+	if req.Header.Get(CountryCodeHeader) != "" {
+		t.Fatalf("requests must pass through unenriched")
+	}
+
+	// Only missing databases are waited for, invalid ones still fail.
+	cfg.DBPath = filepath.Join(t.TempDir(), "GeoLite2-City.mmdb")
+	if err := os.WriteFile(cfg.DBPath, []byte("not a database"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(context.Background(), nil, cfg, "traefik_geoip"); err == nil {
+		t.Fatalf("Must fail on an invalid database")
+	}
+
+	cfg.OnMissingDatabase = "ignore"
+	if _, err := New(context.Background(), nil, cfg, "traefik_geoip"); err == nil {
+		t.Fatalf("Must fail on invalid missing database action")
+	}
+}
+
+func TestMissingDatabaseRetryStops(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.OnMissingDatabase = MissingDatabasePassthrough
+	pending := newPendingLookup()
+	pending.initialBackoff = time.Millisecond
+	pending.maxBackoff = time.Millisecond
+	instance, err := newMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}), cfg, "traefik_geoip", pending.Lookup)
+	if err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+	instance.pending = pending
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		instance.retryDatabase(ctx, func() (LookupGeoIP, error) { return nil, errors.New("not yet") })
+		close(stopped)
+	}()
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("retry must stop once the context is done")
+	}
+	if pending.loaded() {
+		t.Fatalf("database must not be loaded")
+	}
+}
+
+func TestMissingDatabaseRetry(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.OnMissingDatabase = MissingDatabaseDeny
+	cfg.HealthPath = "/health"
+
+	pending := newPendingLookup()
+	pending.initialBackoff = time.Millisecond
+	pending.maxBackoff = 2 * time.Millisecond
+	instance, err := newMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}), cfg, "traefik_geoip", pending.Lookup)
+	if err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+	instance.pending = pending
+
+	recorder, _ := serveRequest(instance, "http://example.com/")
+	assertStatus(t, recorder, http.StatusServiceUnavailable)
+	recorder, _ = serveRequest(instance, "http://example.com/health")
+	assertStatus(t, recorder, http.StatusServiceUnavailable)
+
+	attempts := make(chan struct{}, 10)
+	go instance.retryDatabase(context.Background(), func() (LookupGeoIP, error) {
+		attempts <- struct{}{}
+		if len(attempts) < 3 {
+			return nil, errors.New("not yet")
+		}
+		return staticLookup(munichResult()), nil
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !pending.loaded() {
+		if time.Now().After(deadline) {
+			t.Fatalf("database never loaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	recorder, req := serveRequest(instance, "http://example.com/")
+	assertStatus(t, recorder, http.StatusOK)
+	if req.Header.Get(CountryCodeHeader) != "DE" {
+		t.Fatalf("requests must be enriched once the database is loaded")
+	}
+	recorder, _ = serveRequest(instance, "http://example.com/health")
+	assertStatus(t, recorder, http.StatusOK)
+}
@@ -0,0 +1,76 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+const (
+	// NearestHeader header with the name of the region closest to the client.
+	NearestHeader = "GeoIP-Nearest"
+	// DistanceHeader header with the distance in kilometers between the client and the nearest region.
+	DistanceHeader = "GeoIP-Distance-Km"
+)
+
+// RegionConfig a named reference point, such as a data center.
+type RegionConfig struct {
+	Name      string  `json:"name,omitempty"`
+	Latitude  float64 `json:"latitude,omitempty"`
+	Longitude float64 `json:"longitude,omitempty"`
+}
+
+// nearestRegion finds the region closest to a client.
+type nearestRegion struct {
+	regions  []RegionConfig
+	fallback map[string]string
+}
+
+// newNearestRegion validates the regions and the country fallback. Returns nil when no region is configured.
+func newNearestRegion(regions []RegionConfig, fallback map[string]string) (*nearestRegion, error) {
+	if len(regions) == 0 {
+		if len(fallback) > 0 {
+			return nil, fmt.Errorf("regionFallback requires regions to be configured")
+		}
+		return nil, nil //nolint:nilnil
+	}
+
+	names := map[string]bool{}
+	for i, region := range regions {
+		if region.Name == "" {
+			return nil, fmt.Errorf("invalid region %d: missing name", i)
+		}
+		if math.Abs(region.Latitude) > 90 || math.Abs(region.Longitude) > 180 {
+			return nil, fmt.Errorf("invalid region %s: coordinates out of range", region.Name)
+		}
+		names[region.Name] = true
+	}
+	for countryCode, name := range fallback {
+		if !names[name] {
+			return nil, fmt.Errorf("invalid regionFallback for %s: unknown region %s", countryCode, name)
+		}
+	}
+
+	return &nearestRegion{regions: regions, fallback: fallback}, nil
+}
+
+// find sets the nearest region of the result.
+// When the coordinates are unknown, the region is taken from the country fallback and the distance is left unknown.
+func (n *nearestRegion) find(result *GeoIPResult) {
+	if !result.hasCoordinates() {
+		if name, ok := n.fallback[result.countryCode]; ok {
+			result.nearest = name
+		}
+		return
+	}
+
+	best := -1.0
+	for _, region := range n.regions {
+		distance := haversineKm(result.lat, result.lng, region.Latitude, region.Longitude)
+		if best < 0 || distance < best {
+			best = distance
+			result.nearest = region.Name
+		}
+	}
+	result.distanceKm = strconv.FormatFloat(best, 'f', 0, 64)
+}
@@ -0,0 +1,46 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNearestRegion(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Regions = []RegionConfig{
+		{Name: "eu-central", Latitude: 50.1109, Longitude: 8.6821},
+		{Name: "us-east", Latitude: 38.9072, Longitude: -77.0369},
+	}
+	cfg.RegionFallback = map[string]string{"DE": "eu-central"}
+	instance := newTestMiddleware(t, cfg, munichResult())
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+	req.RemoteAddr = "188.193.88.199:9999"
+	instance.ServeHTTP(httptest.NewRecorder(), req)
+	if req.Header.Get(NearestHeader) != "eu-central" || req.Header.Get(DistanceHeader) != "301" {
+		t.Fatalf("invalid nearest region '%s' at '%s'km", req.Header.Get(NearestHeader), req.Header.Get(DistanceHeader))
+	}
+
+	// Country databases have no coordinates, so the fallback is used.
+	countryOnly := &GeoIPResult{
+		country: "Germany", countryCode: "DE", region: Unknown, city: Unknown,
+		latitude: Unknown, longitude: Unknown, geohash: Unknown,
+	}
+	instance = newTestMiddleware(t, cfg, countryOnly)
+	req = httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+	req.RemoteAddr = "188.193.88.199:9999"
+	instance.ServeHTTP(httptest.NewRecorder(), req)
+	if req.Header.Get(NearestHeader) != "eu-central" || req.Header.Get(DistanceHeader) != "" {
+		t.Fatalf("invalid fallback region '%s' at '%s'km", req.Header.Get(NearestHeader), req.Header.Get(DistanceHeader))
+	}
+}
+
+func TestNearestRegionInvalidConfig(t *testing.T) {
+	if _, err := newNearestRegion([]RegionConfig{{Latitude: 1}}, nil); err == nil {
+		t.Fatalf("Must fail on missing name")
+	}
+	if _, err := newNearestRegion([]RegionConfig{{Name: "a"}}, map[string]string{"DE": "b"}); err == nil {
+		t.Fatalf("Must fail on unknown fallback region")
+	}
+}
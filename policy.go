@@ -0,0 +1,84 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+)
+
+const (
+	// ModeEnforce blocking features answer the requests they block.
+	ModeEnforce = "enforce"
+	// ModeReport blocking features only report the requests they would block, and forward them.
+	ModeReport = "report"
+
+	// PolicyDecisionHeader header describing what a blocking feature would have done in report mode.
+	PolicyDecisionHeader = "GeoIP-Policy-Decision"
+
+	// decisionDeny the request is denied.
+	decisionDeny = "deny"
+	// decisionRedirect the request is redirected.
+	decisionRedirect = "redirect"
+	// decisionLimit the request is over its rate limit.
+	decisionLimit = "limit"
+)
+
+// decision a response sent to the client instead of forwarding the request.
+type decision struct {
+	status int
+	// action is one of the decision constants.
+	action string
+	// rule identifies what took the decision, such as a rule name or "rateLimit:country".
+	rule string
+	// mode overrides the middleware's mode for this decision.
+	mode     string
+	location string
+	header   http.Header
+
+	// ip and result are the client's, for the audit log.
+	ip     net.IP
+	result *GeoIPResult
+}
+
+// validateMode checks the middleware's mode.
+func validateMode(mode string) error {
+	if mode != ModeEnforce && mode != ModeReport {
+		return fmt.Errorf("invalid mode: mode=%s", mode)
+	}
+	return nil
+}
+
+// enforce counts the decision and returns whether the request must be answered with it.
+// In report mode, the request is marked, logged and forwarded instead.
+// Requests with a bypass token are forwarded as well.
+func (mw *TraefikGeoIP) enforce(req *http.Request, dec *decision, bypassID string) bool {
+	if bypassID != "" {
+		mw.metrics.inc("bypass-" + dec.action + ";rule=" + dec.rule)
+		log.Printf("[geoip] request bypassed: action=%s, rule=%s, bypass=%s, host=%s, path=%s, name=%s",
+			dec.action, dec.rule, bypassID, req.Host, req.URL.Path, mw.name)
+		return false
+	}
+
+	// Decisions on simulated requests are counted apart, so tests do not skew the real counters.
+	counter := dec.action + ";rule=" + dec.rule
+	if req.Header.Get(SimulatedHeader) != "" {
+		counter += ";simulated"
+	}
+
+	mode := dec.mode
+	if mode == "" {
+		mode = mw.mode
+	}
+
+	if mode == ModeReport {
+		mw.metrics.inc("would-" + counter)
+		req.Header.Add(PolicyDecisionHeader, "would-"+dec.action+"; rule="+dec.rule)
+		log.Printf("[geoip] request would be blocked: action=%s, rule=%s, host=%s, path=%s, name=%s",
+			dec.action, dec.rule, req.Host, req.URL.Path, mw.name)
+		return false
+	}
+
+	mw.metrics.inc(counter)
+	return true
+}
@@ -0,0 +1,112 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReportMode(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Mode = ModeReport
+	cfg.Rules = []RuleConfig{
+		{Name: "deny-de", Countries: []string{"DE"}, Action: RuleActionDeny},
+	}
+	cfg.Redirects = map[string]string{"DE": "https://de.example.com{path}"}
+
+	var forwarded *http.Request
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) { forwarded = req })
+	instance, err := newMiddleware(next, cfg, "traefik_geoip", staticLookup(munichResult()))
+	if err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		forwarded = nil
+		recorder, _ := serveRequest(instance, "http://example.com/")
+		assertStatus(t, recorder, http.StatusOK)
+		if forwarded == nil {
+			t.Fatalf("request must be forwarded in report mode")
+		}
+	}
+
+	decisions := forwarded.Header.Values(PolicyDecisionHeader)
+	if len(decisions) != 2 || decisions[0] != "would-deny; rule=deny-de" || decisions[1] != "would-redirect; rule=redirect:DE" {
+		t.Fatalf("invalid policy decisions %v", decisions)
+	}
+	if forwarded.Header.Get(CountryCodeHeader) != "DE" {
+		t.Fatalf("reported requests must still be enriched")
+	}
+
+	counters := instance.Metrics()
+	if counters["would-deny;rule=deny-de"] != 2 || counters["would-redirect;rule=redirect:DE"] != 2 {
+		t.Fatalf("invalid metrics %v", counters)
+	}
+
+	// Decisions sent by the client are replaced by the middleware's own.
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.RemoteAddr = "188.193.88.199:9999"
+	req.Header.Add(PolicyDecisionHeader, "would-deny; rule=forged")
+	instance.ServeHTTP(httptest.NewRecorder(), req)
+	if decisions := forwarded.Header.Values(PolicyDecisionHeader); len(decisions) != 2 || decisions[0] != "would-deny; rule=deny-de" {
+		t.Fatalf("invalid policy decisions %v", decisions)
+	}
+
+	// Without report-mode decisions, the header is removed.
+	cfg.Mode = ModeEnforce
+	cfg.Rules = nil
+	cfg.Redirects = nil
+	if instance, err = newMiddleware(next, cfg, "traefik_geoip", staticLookup(munichResult())); err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+	req.Header.Set(PolicyDecisionHeader, "would-deny; rule=forged")
+	instance.ServeHTTP(httptest.NewRecorder(), req)
+	if decisions := forwarded.Header.Values(PolicyDecisionHeader); len(decisions) != 0 {
+		t.Fatalf("forged policy decisions must not be forwarded, got %v", decisions)
+	}
+}
+
+func TestReportModePerRule(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Rules = []RuleConfig{
+		{Name: "trial", Countries: []string{"DE"}, Action: RuleActionDeny, Mode: ModeReport},
+		{Name: "enforced", Countries: []string{"DE"}, PathPrefixes: []string{"/admin"}, Action: RuleActionDeny},
+	}
+	instance := newTestMiddleware(t, cfg, munichResult())
+
+	recorder, req := serveRequest(instance, "http://example.com/")
+	assertStatus(t, recorder, http.StatusOK)
+	if req.Header.Get(PolicyDecisionHeader) != "would-deny; rule=trial" {
+		t.Fatalf("invalid policy decision '%s'", req.Header.Get(PolicyDecisionHeader))
+	}
+
+	// A reported rule does not end the evaluation, the enforced rules after it still apply.
+	recorder, _ = serveRequest(instance, "http://example.com/admin")
+	assertStatus(t, recorder, http.StatusForbidden)
+	counters := instance.Metrics()
+	if counters["would-deny;rule=trial"] != 2 || counters["deny;rule=enforced"] != 1 {
+		t.Fatalf("invalid metrics %v", counters)
+	}
+
+	cfg.Rules[1].Mode = "dry"
+	if _, err := newMiddleware(nil, cfg, "traefik_geoip", staticLookup(munichResult())); err == nil {
+		t.Fatalf("Must fail on invalid rule mode")
+	}
+	cfg.Rules = nil
+	cfg.Mode = ""
+	if _, err := newMiddleware(nil, cfg, "traefik_geoip", staticLookup(munichResult())); err == nil {
+		t.Fatalf("Must fail on invalid mode")
+	}
+}
+
+func TestEnforcedDecisionsAreCounted(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Rules = []RuleConfig{{Name: "deny-all", Action: RuleActionDeny}}
+	instance := newTestMiddleware(t, cfg, munichResult())
+
+	recorder, _ := serveRequest(instance, "http://example.com/")
+	assertStatus(t, recorder, http.StatusForbidden)
+	if instance.Metrics()["deny;rule=deny-all"] != 1 {
+		t.Fatalf("invalid metrics %v", instance.Metrics())
+	}
+}
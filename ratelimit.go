@@ -0,0 +1,295 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// RateLimitKeyCountry limits requests per country code.
+	RateLimitKeyCountry = "country"
+	// RateLimitKeyASN limits requests per autonomous system number.
+	RateLimitKeyASN = "asn"
+	// RateLimitKeyRegion limits requests per country code and region, such as "DE-BY".
+	RateLimitKeyRegion = "region"
+	// RateLimitKeyIP limits requests per client IP, with limits chosen by the client's country.
+	RateLimitKeyIP = "ip"
+
+	// defaultRateLimitPeriod default period of rate limits.
+	defaultRateLimitPeriod = time.Second
+	// defaultRateLimitMaxBuckets default number of buckets kept in memory.
+	defaultRateLimitMaxBuckets = 10000
+	// defaultRateLimitIdleTimeout default time after which an unused bucket is dropped.
+	defaultRateLimitIdleTimeout = 10 * time.Minute
+)
+
+// RateLimit allows Average requests per Period, with bursts of up to Burst requests.
+// Average 0 disables the limit.
+type RateLimit struct {
+	Average int64  `json:"average,omitempty"`
+	Period  string `json:"period,omitempty"`
+	Burst   int64  `json:"burst,omitempty"`
+}
+
+// RateLimitConfig configures the geo rate limiter.
+type RateLimitConfig struct {
+	// Key is the dimension requests are grouped by: "country", "asn", "region" or "ip". Disabled when empty.
+	Key string `json:"key,omitempty"`
+	// Default limit of keys without their own limit.
+	Default RateLimit `json:"default,omitempty"`
+	// Limits per key value. For the "ip" key, limits are keyed by country code and apply to each IP of that country.
+	Limits map[string]RateLimit `json:"limits,omitempty"`
+	// MaxBuckets bounds the number of buckets kept in memory. The least recently used bucket is dropped first.
+	MaxBuckets int `json:"maxBuckets,omitempty"`
+	// IdleTimeout after which an unused bucket is dropped.
+	IdleTimeout string `json:"idleTimeout,omitempty"`
+}
+
+// tokenRate a parsed RateLimit.
+type tokenRate struct {
+	perSecond float64
+	burst     float64
+}
+
+// newTokenRate parses a RateLimit. Returns nil when the limit is disabled.
+func newTokenRate(limit RateLimit) (*tokenRate, error) {
+	if limit.Average < 0 || limit.Burst < 0 {
+		return nil, fmt.Errorf("average and burst must not be negative")
+	}
+	if limit.Average == 0 {
+		return nil, nil //nolint:nilnil
+	}
+
+	period := defaultRateLimitPeriod
+	if limit.Period != "" {
+		var err error
+		if period, err = time.ParseDuration(limit.Period); err != nil || period <= 0 {
+			return nil, fmt.Errorf("invalid period: period=%s", limit.Period)
+		}
+	}
+
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &tokenRate{perSecond: float64(limit.Average) / period.Seconds(), burst: burst}, nil
+}
+
+// tokenBucket the state of a single key.
+type tokenBucket struct {
+	key      string
+	tokens   float64
+	lastSeen time.Time
+}
+
+// rateLimiter a memory-bounded set of token buckets.
+type rateLimiter struct {
+	key         string
+	defaultRate *tokenRate
+	rates       map[string]*tokenRate
+
+	maxBuckets  int
+	idleTimeout time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	// lru holds the buckets from the most to the least recently used.
+	lru *list.List
+}
+
+// newRateLimiter validates the config and creates the limiter. Returns nil when rate limiting is disabled.
+// Limits of the "country" and "ip" keys can also be keyed by country group.
+func newRateLimiter(cfg RateLimitConfig, groups *countryGroups) (*rateLimiter, error) {
+	if cfg.Key == "" {
+		return nil, nil //nolint:nilnil
+	}
+	switch cfg.Key {
+	case RateLimitKeyCountry, RateLimitKeyASN, RateLimitKeyRegion, RateLimitKeyIP:
+	default:
+		return nil, fmt.Errorf("invalid rate limit key: key=%s", cfg.Key)
+	}
+
+	defaultRate, err := newTokenRate(cfg.Default)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default rate limit: %w", err)
+	}
+
+	limiter := &rateLimiter{
+		key:         cfg.Key,
+		defaultRate: defaultRate,
+		rates:       map[string]*tokenRate{},
+		maxBuckets:  cfg.MaxBuckets,
+		idleTimeout: defaultRateLimitIdleTimeout,
+		now:         time.Now,
+		buckets:     map[string]*list.Element{},
+		lru:         list.New(),
+	}
+	if limiter.maxBuckets <= 0 {
+		limiter.maxBuckets = defaultRateLimitMaxBuckets
+	}
+	if cfg.IdleTimeout != "" {
+		if limiter.idleTimeout, err = time.ParseDuration(cfg.IdleTimeout); err != nil || limiter.idleTimeout <= 0 {
+			return nil, fmt.Errorf("invalid rate limit idle timeout: timeout=%s", cfg.IdleTimeout)
+		}
+	}
+
+	byCountry := cfg.Key == RateLimitKeyCountry || cfg.Key == RateLimitKeyIP
+	rates := map[string]*tokenRate{}
+	for value, limit := range cfg.Limits {
+		rate, err := newTokenRate(limit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit for %s: %w", value, err)
+		}
+		if !byCountry {
+			if isGroup(value) {
+				return nil, fmt.Errorf("invalid rate limit for %s: country groups require the country or ip key", value)
+			}
+			limiter.rates[normalizeRateLimitValue(cfg.Key, value)] = rate
+			continue
+		}
+		rates[value] = rate
+	}
+
+	if byCountry {
+		keys := make([]string, 0, len(rates))
+		for value := range rates {
+			keys = append(keys, value)
+		}
+		countries, err := groups.expandKeys(keys)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit: %w", err)
+		}
+		for code, value := range countries {
+			limiter.rates[code] = rates[value]
+		}
+	}
+
+	return limiter, nil
+}
+
+// normalizeRateLimitValue makes config keys match the values computed from lookups.
+func normalizeRateLimitValue(key, value string) string {
+	value = strings.TrimSpace(value)
+	if key == RateLimitKeyASN {
+		return strings.TrimPrefix(strings.ToUpper(value), "AS")
+	}
+	return strings.ToUpper(value)
+}
+
+// known checks if a lookup value is set.
+func known(value string) bool {
+	return value != "" && value != Unknown
+}
+
+// keyOf returns the bucket key of the request and the value used to choose its limit.
+// Both are empty if the request can't be grouped.
+func (rl *rateLimiter) keyOf(ip net.IP, result *GeoIPResult) (string, string) {
+	switch rl.key {
+	case RateLimitKeyCountry:
+		if known(result.countryCode) {
+			return result.countryCode, result.countryCode
+		}
+	case RateLimitKeyASN:
+		if known(result.asn) {
+			return result.asn, result.asn
+		}
+	case RateLimitKeyRegion:
+		if known(result.countryCode) && known(result.region) {
+			value := result.countryCode + "-" + result.region
+			return value, value
+		}
+	case RateLimitKeyIP:
+		if known(result.countryCode) {
+			return result.countryCode + "/" + ip.String(), result.countryCode
+		}
+	}
+	return "", ""
+}
+
+// allow takes a token from the request's bucket.
+// When the bucket is empty, it returns false and how long until a token is available.
+func (rl *rateLimiter) allow(ip net.IP, result *GeoIPResult) (bool, time.Duration) {
+	key, value := rl.keyOf(ip, result)
+	if key == "" {
+		return true, 0
+	}
+	rate, ok := rl.rates[strings.ToUpper(value)]
+	if !ok {
+		rate = rl.defaultRate
+	}
+	if rate == nil {
+		return true, 0
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	rl.evictIdle(now)
+
+	var bucket *tokenBucket
+	if element, ok := rl.buckets[key]; ok {
+		rl.lru.MoveToFront(element)
+		bucket = element.Value.(*tokenBucket) //nolint:forcetypeassert
+		elapsed := now.Sub(bucket.lastSeen).Seconds()
+		bucket.tokens = math.Min(rate.burst, bucket.tokens+elapsed*rate.perSecond)
+	} else {
+		bucket = &tokenBucket{key: key, tokens: rate.burst}
+		rl.buckets[key] = rl.lru.PushFront(bucket)
+		for rl.lru.Len() > rl.maxBuckets {
+			rl.remove(rl.lru.Back())
+		}
+	}
+	bucket.lastSeen = now
+
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / rate.perSecond * float64(time.Second))
+	}
+	bucket.tokens--
+
+	return true, 0
+}
+
+// evictIdle drops the buckets unused for longer than the idle timeout.
+func (rl *rateLimiter) evictIdle(now time.Time) {
+	for element := rl.lru.Back(); element != nil; element = rl.lru.Back() {
+		if now.Sub(element.Value.(*tokenBucket).lastSeen) < rl.idleTimeout { //nolint:forcetypeassert
+			return
+		}
+		rl.remove(element)
+	}
+}
+
+// remove drops a bucket.
+func (rl *rateLimiter) remove(element *list.Element) {
+	rl.lru.Remove(element)
+	delete(rl.buckets, element.Value.(*tokenBucket).key) //nolint:forcetypeassert
+}
+
+// check answers 429 to requests over their limit.
+func (rl *rateLimiter) check(ip net.IP, result *GeoIPResult) *decision {
+	allowed, retryAfter := rl.allow(ip, result)
+	if allowed {
+		return nil
+	}
+
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return &decision{
+		status: http.StatusTooManyRequests,
+		action: decisionLimit,
+		rule:   "rateLimit:" + rl.key,
+		header: http.Header{"Retry-After": []string{strconv.FormatInt(seconds, 10)}},
+	}
+}
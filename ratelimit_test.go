@@ -0,0 +1,94 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitPerCountry(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.RateLimit = RateLimitConfig{
+		Key:     RateLimitKeyCountry,
+		Default: RateLimit{Average: 100, Burst: 100},
+		Limits:  map[string]RateLimit{"de": {Average: 1, Period: "2s", Burst: 2}},
+	}
+	instance := newTestMiddleware(t, cfg, munichResult())
+	now := time.Unix(0, 0)
+	instance.rateLimiter.now = func() time.Time { return now }
+
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+		req.RemoteAddr = "188.193.88.199:9999"
+		recorder := httptest.NewRecorder()
+		instance.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	assertStatus(t, serve(), http.StatusOK)
+	assertStatus(t, serve(), http.StatusOK)
+	recorder := serve()
+	assertStatus(t, recorder, http.StatusTooManyRequests)
+	if recorder.Header().Get("Retry-After") != "2" {
+		t.Fatalf("invalid Retry-After '%s'", recorder.Header().Get("Retry-After"))
+	}
+
+	now = now.Add(2 * time.Second)
+	assertStatus(t, serve(), http.StatusOK)
+	assertStatus(t, serve(), http.StatusTooManyRequests)
+}
+
+func TestRateLimitBucketsAreBounded(t *testing.T) {
+	limiter, err := newRateLimiter(RateLimitConfig{
+		Key:         RateLimitKeyIP,
+		Default:     RateLimit{Average: 1, Period: "1m"},
+		MaxBuckets:  2,
+		IdleTimeout: "1m",
+	}, builtinGroups(t))
+	if err != nil {
+		t.Fatalf("unable to create limiter: %v", err)
+	}
+	now := time.Unix(0, 0)
+	limiter.now = func() time.Time { return now }
+
+	result := munichResult()
+	for _, ip := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		if allowed, _ := limiter.allow(net.ParseIP(ip), result); !allowed {
+			t.Fatalf("first request of %s must be allowed", ip)
+		}
+	}
+	if limiter.lru.Len() != 2 {
+		t.Fatalf("invalid number of buckets %d", limiter.lru.Len())
+	}
+	// The least recently used bucket was dropped, so its IP starts over.
+	if allowed, _ := limiter.allow(net.ParseIP("1.1.1.1"), result); !allowed {
+		t.Fatalf("evicted bucket must start over")
+	}
+
+	now = now.Add(time.Minute)
+	if allowed, _ := limiter.allow(net.ParseIP("4.4.4.4"), result); !allowed || limiter.lru.Len() != 1 {
+		t.Fatalf("idle buckets must be dropped, %d left", limiter.lru.Len())
+	}
+}
+
+func TestRateLimitUnknownKey(t *testing.T) {
+	limiter, err := newRateLimiter(RateLimitConfig{Key: RateLimitKeyASN, Default: RateLimit{Average: 1}}, builtinGroups(t))
+	if err != nil {
+		t.Fatalf("unable to create limiter: %v", err)
+	}
+	// Results without an ASN are never limited.
+	for i := 0; i < 3; i++ {
+		if allowed, _ := limiter.allow(net.ParseIP("1.1.1.1"), munichResult()); !allowed {
+			t.Fatalf("requests without an ASN must be allowed")
+		}
+	}
+
+	if _, err := newRateLimiter(RateLimitConfig{Key: "city"}, builtinGroups(t)); err == nil {
+		t.Fatalf("Must fail on invalid key")
+	}
+	if _, err := newRateLimiter(RateLimitConfig{Key: RateLimitKeyASN, Default: RateLimit{Average: 1, Period: "x"}}, builtinGroups(t)); err == nil {
+		t.Fatalf("Must fail on invalid period")
+	}
+}
@@ -0,0 +1,179 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// defaultRedirectStatusCode default status code of country redirects.
+	defaultRedirectStatusCode = http.StatusFound
+	// redirectDefaultKey redirect key used for countries without their own target.
+	redirectDefaultKey = "*"
+)
+
+// redirector sends clients to their country's target URL.
+type redirector struct {
+	targets      map[string]string
+	statusCode   int
+	optOutCookie string
+	optOutQuery  string
+	rewrite      bool
+}
+
+// newRedirector validates the redirect config. Returns nil when no redirect is configured.
+// Keys are country codes, country groups, comma separated lists of both, or "*" for every other country.
+func newRedirector(cfg *Config, groups *countryGroups) (*redirector, error) {
+	if len(cfg.Redirects) == 0 {
+		return nil, nil //nolint:nilnil
+	}
+
+	if cfg.RedirectStatusCode < 300 || cfg.RedirectStatusCode > 399 {
+		return nil, fmt.Errorf("invalid redirect status code: code=%d", cfg.RedirectStatusCode)
+	}
+
+	r := &redirector{
+		targets:      map[string]string{},
+		statusCode:   cfg.RedirectStatusCode,
+		optOutCookie: cfg.RedirectOptOutCookie,
+		optOutQuery:  cfg.RedirectOptOutQuery,
+		rewrite:      cfg.RedirectRewrite,
+	}
+	keys := []string{}
+	for key, target := range cfg.Redirects {
+		if _, err := url.Parse(renderRedirect(target, "XX", "example.com", "/", "")); err != nil {
+			return nil, fmt.Errorf("invalid redirect target for %s: %w", key, err)
+		}
+		if key == redirectDefaultKey {
+			r.targets[redirectDefaultKey] = target
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	byCountry, err := groups.expandKeys(keys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirect: %w", err)
+	}
+	for code, key := range byCountry {
+		r.targets[code] = cfg.Redirects[key]
+	}
+
+	return r, nil
+}
+
+// renderRedirect replaces the placeholders of a target template.
+func renderRedirect(template, countryCode, host, path, rawQuery string) string {
+	query := ""
+	if rawQuery != "" {
+		query = "?" + rawQuery
+	}
+	return strings.NewReplacer(
+		"{cc}", strings.ToLower(countryCode),
+		"{CC}", strings.ToUpper(countryCode),
+		"{host}", host,
+		"{path}", path,
+		"{query}", query,
+	).Replace(template)
+}
+
+// requestScheme returns the scheme the client used to reach the proxy.
+func requestScheme(req *http.Request) string {
+	if proto := req.Header.Get("X-Forwarded-Proto"); proto != "" {
+		return proto
+	}
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// optedOut checks if the client asked not to be redirected.
+func (r *redirector) optedOut(req *http.Request) bool {
+	if r.optOutQuery != "" && req.URL.Query().Has(r.optOutQuery) {
+		return true
+	}
+	if r.optOutCookie != "" {
+		if cookie, err := req.Cookie(r.optOutCookie); err == nil && cookie.Value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// target returns the rendered target of the country, or an empty string if the request must not be redirected.
+func (r *redirector) target(req *http.Request, countryCode string) string {
+	template, ok := r.targets[strings.ToUpper(countryCode)]
+	if !ok {
+		template, ok = r.targets[redirectDefaultKey]
+	}
+	if !ok || r.optedOut(req) {
+		return ""
+	}
+
+	return redirectTarget(req, template, countryCode)
+}
+
+// redirectTarget renders the template for the request, or returns an empty string if the request already is on it.
+// Requests that already are on their target are never redirected, which prevents redirect loops.
+func redirectTarget(req *http.Request, template, countryCode string) string {
+	target := renderRedirect(template, countryCode, req.Host, req.URL.Path, req.URL.RawQuery)
+
+	// Compare the target and the current URL in the form of the template, relative or absolute.
+	current := req.URL.Path
+	if strings.Contains(template, "://") {
+		current = requestScheme(req) + "://" + req.Host + current
+	}
+	if req.URL.RawQuery != "" {
+		current += "?" + req.URL.RawQuery
+	}
+	if target == current {
+		return ""
+	}
+	if prefix := strings.SplitN(template, "{path}", 2); len(prefix) == 2 {
+		rendered := renderRedirect(prefix[0], countryCode, req.Host, "", "")
+		if rendered != "" && (current == rendered || strings.HasPrefix(current, rendered+"/")) {
+			return ""
+		}
+	}
+
+	return target
+}
+
+// apply redirects or rewrites the request to its country's target.
+func (r *redirector) apply(req *http.Request, result *GeoIPResult) (*http.Request, *decision) {
+	if result.countryCode == Unknown || result.countryCode == "" {
+		return req, nil
+	}
+
+	target := r.target(req, result.countryCode)
+	if target == "" {
+		return req, nil
+	}
+
+	if !r.rewrite {
+		return req, &decision{
+			status:   r.statusCode,
+			action:   decisionRedirect,
+			rule:     "redirect:" + result.countryCode,
+			location: target,
+		}
+	}
+
+	rewritten, err := url.Parse(target)
+	if err != nil {
+		return req, nil
+	}
+	if rewritten.Host != "" {
+		req.Host = rewritten.Host
+		req.URL.Host = rewritten.Host
+	}
+	req.URL.Path = rewritten.Path
+	req.URL.RawPath = rewritten.RawPath
+	req.URL.RawQuery = rewritten.RawQuery
+	req.RequestURI = req.URL.RequestURI()
+
+	return req, nil
+}
@@ -0,0 +1,87 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirect(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Redirects = map[string]string{
+		"DE,AT": "https://{cc}.example.com{path}{query}",
+		"*":     "https://www.example.com{path}",
+	}
+	cfg.RedirectOptOutQuery = "noredirect"
+	cfg.RedirectOptOutCookie = "stay"
+	instance := newTestMiddleware(t, cfg, munichResult())
+
+	cases := []struct {
+		url      string
+		cookie   bool
+		location string
+	}{
+		{"http://example.com/shop?item=1", false, "https://de.example.com/shop?item=1"},
+		{"https://de.example.com/shop", false, ""},
+		{"http://example.com/shop?noredirect", false, ""},
+		{"http://example.com/shop", true, ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.url, nil)
+		req.RemoteAddr = "188.193.88.199:9999"
+		req.Header.Set("X-Forwarded-Proto", req.URL.Scheme)
+		if c.cookie {
+			req.AddCookie(&http.Cookie{Name: "stay", Value: "1"})
+		}
+		recorder := httptest.NewRecorder()
+		instance.ServeHTTP(recorder, req)
+
+		if c.location == "" {
+			assertStatus(t, recorder, http.StatusOK)
+			continue
+		}
+		assertStatus(t, recorder, http.StatusFound)
+		if recorder.Header().Get("Location") != c.location {
+			t.Fatalf("invalid redirect of %s: '%s', not '%s'", c.url, recorder.Header().Get("Location"), c.location)
+		}
+	}
+}
+
+func TestRedirectRewrite(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Redirects = map[string]string{"DE": "/{cc}{path}"}
+	cfg.RedirectRewrite = true
+
+	var forwarded *http.Request
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) { forwarded = req })
+	instance, err := newMiddleware(next, cfg, "traefik_geoip", staticLookup(munichResult()))
+	if err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+
+	for _, path := range []string{"/shop", "/de/shop"} {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+		req.RemoteAddr = "188.193.88.199:9999"
+		recorder := httptest.NewRecorder()
+		instance.ServeHTTP(recorder, req)
+		assertStatus(t, recorder, http.StatusOK)
+		if forwarded.URL.Path != "/de/shop" {
+			t.Fatalf("invalid rewritten path of %s: '%s'", path, forwarded.URL.Path)
+		}
+	}
+}
+
+func TestRedirectInvalidConfig(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Redirects = map[string]string{"DE": "/de{path}"}
+	cfg.RedirectStatusCode = http.StatusOK
+	if _, err := newRedirector(cfg, builtinGroups(t)); err == nil {
+		t.Fatalf("Must fail on invalid status code")
+	}
+
+	cfg.RedirectStatusCode = http.StatusFound
+	cfg.Redirects = map[string]string{"DE,": "/de{path}"}
+	if _, err := newRedirector(cfg, builtinGroups(t)); err == nil {
+		t.Fatalf("Must fail on invalid key")
+	}
+}
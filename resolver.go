@@ -0,0 +1,69 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"errors"
+	"net"
+	"net/http"
+)
+
+// ErrNoClientIP the client IP of the request could not be determined, or is excluded.
+var ErrNoClientIP = errors.New("no client IP")
+
+// Resolver looks up IPs and requests like the middleware does, for Go programs that do not run in Traefik.
+type Resolver struct {
+	mw *TraefikGeoIP
+}
+
+// NewResolver opens the databases of the config and creates a resolver.
+// Only the options about the lookup apply, such as excluded IPs, geohash precision, geofences, regions,
+// locale and groups.
+func NewResolver(cfg *Config) (*Resolver, error) {
+	lookup, err := openLookup(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewResolverWithLookup(cfg, lookup)
+}
+
+// NewResolverWithLookup creates a resolver around an already initialized lookup, such as one from NewLookup.
+func NewResolverWithLookup(cfg *Config, lookup LookupGeoIP) (*Resolver, error) {
+	mw, err := newLookupMiddleware(cfg, "resolver", lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Resolver{mw: mw}, nil
+}
+
+// Lookup looks the IP up. Excluded IPs are looked up as well.
+func (r *Resolver) Lookup(ip net.IP) (*Result, error) {
+	result, err := r.mw.lookupIP(&http.Request{Header: http.Header{}}, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	typed := result.Result()
+	typed.IP = ip
+	return typed, nil
+}
+
+// Resolve finds the client IP of the request like the middleware, and looks it up.
+// The locale is chosen with the request's Accept-Language. The request is not modified.
+func (r *Resolver) Resolve(req *http.Request) (*Result, error) {
+	req = req.Clone(req.Context())
+
+	ip := r.mw.getClientIP(req)
+	if ip == nil {
+		return nil, ErrNoClientIP
+	}
+
+	result, err := r.mw.lookupIP(req, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	typed := result.Result()
+	typed.IP = ip
+	return typed, nil
+}
@@ -0,0 +1,117 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResolver(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.ExcludeIPs = []string{"10.0.0.0/8"}
+	cfg.LocaleHeaders = true
+	result := munichResult()
+	result.asn = "3320"
+	result.eu = "true"
+	resolver, err := NewResolverWithLookup(cfg, staticLookup(result))
+	if err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+
+	typed, err := resolver.Lookup(net.ParseIP("188.193.88.199"))
+	if err != nil {
+		t.Fatalf("Error looking up %v", err)
+	}
+	if typed.CountryCode != "DE" || typed.ASN != 3320 || !typed.InEuropeanUnion || !typed.HasCoordinates ||
+		typed.Latitude != 48.1663 || typed.Longitude != 11.5683 || typed.Currency != "EUR" ||
+		!reflect.DeepEqual(typed.Languages, []string{"de"}) || !typed.IP.Equal(net.ParseIP("188.193.88.199")) {
+		t.Fatalf("invalid result %+v", typed)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.RemoteAddr = "10.0.0.1:9999"
+	if _, err := resolver.Resolve(req); !errors.Is(err, ErrNoClientIP) {
+		t.Fatalf("excluded IPs must not resolve, got %v", err)
+	}
+	req.Header.Set("X-Forwarded-For", "188.193.88.199")
+	if typed, err = resolver.Resolve(req); err != nil || typed.City != "Munich" {
+		t.Fatalf("invalid resolved result %+v, %v", typed, err)
+	}
+
+	unknown := russianResult("")
+	if typed = unknown.Result(); typed.Region != "" || typed.HasCoordinates || typed.ASN != 0 {
+		t.Fatalf("unknown values must be empty %+v", typed)
+	}
+}
+
+func TestResolverOnlyBuildsLookup(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.GroupsHeader = true
+	cfg.Audit.Path = filepath.Join(t.TempDir(), "audit.log")
+	cfg.RateLimit = RateLimitConfig{Key: RateLimitKeyCountry, Default: RateLimit{Average: 10, Burst: 10}}
+	resolver, err := NewResolverWithLookup(cfg, staticLookup(munichResult()))
+	if err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+	if resolver.mw.auditSink != nil || resolver.mw.rateLimiter != nil {
+		t.Fatalf("resolvers must not open audit logs or build rate limiters")
+	}
+
+	// Results are copies, changing them does not change later ones.
+	typed, err := resolver.Lookup(net.ParseIP("188.193.88.199"))
+	if err != nil || len(typed.Groups) == 0 {
+		t.Fatalf("invalid result %+v, %v", typed, err)
+	}
+	groups := append([]string{}, typed.Groups...)
+	typed.Groups[0] = "changed"
+	if typed, _ = resolver.Lookup(net.ParseIP("188.193.88.199")); !reflect.DeepEqual(typed.Groups, groups) {
+		t.Fatalf("groups must not be shared, got %v", typed.Groups)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	var typed *Result
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		typed, _ = FromContext(req.Context())
+	})
+	instance, err := newMiddleware(next, CreateConfig(), "traefik_geoip", staticLookup(munichResult()))
+	if err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+
+	serveRequest(instance, "http://example.com/")
+	if typed == nil || typed.CountryCode != "DE" || typed.IP.String() != "188.193.88.199" {
+		t.Fatalf("invalid result in context %+v", typed)
+	}
+
+	if _, ok := FromContext(httptest.NewRequest(http.MethodGet, "http://example.com/", nil).Context()); ok {
+		t.Fatalf("requests without a result must not have one")
+	}
+
+	// Skipped requests get neither headers nor a result.
+	cfg := CreateConfig()
+	cfg.Rules = []RuleConfig{{Name: "skip-health", PathPrefixes: []string{"/health"}, Action: RuleActionSkip}}
+	instance, err = newMiddleware(next, cfg, "traefik_geoip", staticLookup(munichResult()))
+	if err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+	typed = nil
+	serveRequest(instance, "http://example.com/health")
+	if typed != nil {
+		t.Fatalf("skipped requests must not have a result %+v", typed)
+	}
+}
+
+func TestResultFields(t *testing.T) {
+	fields := munichResult().Result().Fields()
+	if len(fields) != 7 || fields[0] != (Field{Name: "country", Header: CountryHeader, Value: "Germany"}) {
+		t.Fatalf("invalid fields %v", fields)
+	}
+	if names := FieldNames(); len(names) != len((&GeoIPResult{}).allFields()) || names[1] != "countryCode" {
+		t.Fatalf("invalid field names %v", names)
+	}
+}
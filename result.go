@@ -0,0 +1,149 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Result the geo data of an IP with typed values, for Go programs using the package.
+// Unknown values are left empty, instead of the headers' "XX".
+type Result struct {
+	IP          net.IP `json:"ip,omitempty"`
+	Country     string `json:"country,omitempty"`
+	CountryCode string `json:"countryCode,omitempty"`
+	Region      string `json:"region,omitempty"`
+	City        string `json:"city,omitempty"`
+
+	// HasCoordinates tells if Latitude and Longitude are known.
+	HasCoordinates bool    `json:"hasCoordinates"`
+	Latitude       float64 `json:"latitude,omitempty"`
+	Longitude      float64 `json:"longitude,omitempty"`
+	// AccuracyRadius in kilometers around the coordinates, 0 if unknown.
+	AccuracyRadius uint16 `json:"accuracyRadius,omitempty"`
+	Geohash        string `json:"geohash,omitempty"`
+
+	// ASN is the autonomous system number, 0 if unknown.
+	ASN            uint32 `json:"asn,omitempty"`
+	ASOrganization string `json:"asOrganization,omitempty"`
+
+	InEuropeanUnion bool `json:"inEuropeanUnion"`
+	// CountrySource is empty for the physical country, or CountrySourceRegistered for the registered country.
+	CountrySource          string `json:"countrySource,omitempty"`
+	RegisteredCountryCode  string `json:"registeredCountryCode,omitempty"`
+	RepresentedCountryCode string `json:"representedCountryCode,omitempty"`
+
+	AnonymousProxy    bool `json:"anonymousProxy"`
+	SatelliteProvider bool `json:"satelliteProvider"`
+
+	Groups    []string `json:"groups,omitempty"`
+	Geofences []string `json:"geofences,omitempty"`
+	Nearest   string   `json:"nearest,omitempty"`
+	// DistanceKm to the nearest region, rounded to the kilometer.
+	DistanceKm float64 `json:"distanceKm,omitempty"`
+
+	Currency    string   `json:"currency,omitempty"`
+	Languages   []string `json:"languages,omitempty"`
+	CallingCode string   `json:"callingCode,omitempty"`
+	Locale      string   `json:"locale,omitempty"`
+
+	fields []Field
+}
+
+// Field a known value of a result, as the middleware sets it.
+type Field struct {
+	// Name of the value in the data header and the self-lookup endpoint.
+	Name string
+	// Header the value is set in.
+	Header string
+	Value  string
+}
+
+// Fields returns the known values of the result as the middleware sets them, in the order of FieldNames.
+func (r *Result) Fields() []Field {
+	return r.fields
+}
+
+// FieldNames returns the names of every value the middleware can set, in a stable order.
+func FieldNames() []string {
+	all := (&GeoIPResult{}).allFields()
+	names := make([]string, 0, len(all))
+	for _, field := range all {
+		names = append(names, field.name)
+	}
+	return names
+}
+
+// knownOrEmpty returns the value, or an empty string when it is unknown.
+func knownOrEmpty(value string) string {
+	if value == Unknown {
+		return ""
+	}
+	return value
+}
+
+// Result returns the typed values of the lookup result.
+func (r *GeoIPResult) Result() *Result {
+	result := &Result{
+		Country:                knownOrEmpty(r.country),
+		CountryCode:            knownOrEmpty(r.countryCode),
+		Region:                 knownOrEmpty(r.region),
+		City:                   knownOrEmpty(r.city),
+		AccuracyRadius:         r.accuracyRadius,
+		Geohash:                knownOrEmpty(r.geohash),
+		ASOrganization:         knownOrEmpty(r.asOrg),
+		InEuropeanUnion:        r.eu == "true",
+		CountrySource:          r.countrySource,
+		RegisteredCountryCode:  r.registeredCountryCode,
+		RepresentedCountryCode: r.representedCountryCode,
+		AnonymousProxy:         r.anonymousProxy,
+		SatelliteProvider:      r.satelliteProvider,
+		Groups:                 copyStrings(r.groups),
+		Geofences:              copyStrings(r.geofences),
+		Nearest:                r.nearest,
+		Currency:               r.currency,
+		CallingCode:            r.callingCode,
+		Locale:                 r.locale,
+	}
+	if r.hasCoordinates() {
+		result.HasCoordinates = true
+		result.Latitude, result.Longitude = r.lat, r.lng
+	}
+	if asn, err := strconv.ParseUint(r.asn, 10, 32); err == nil {
+		result.ASN = uint32(asn)
+	}
+	if distance, err := strconv.ParseFloat(r.distanceKm, 64); err == nil {
+		result.DistanceKm = distance
+	}
+	if r.languages != "" {
+		result.Languages = strings.Split(r.languages, ",")
+	}
+	for _, field := range r.fields() {
+		result.fields = append(result.fields, Field{Name: field.name, Header: field.header, Value: field.value})
+	}
+	return result
+}
+
+// copyStrings copies a slice, so callers can not modify the middleware's own, such as the country groups.
+func copyStrings(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	return append([]string{}, values...)
+}
+
+// resultKey the context key of the Result.
+type resultKey struct{}
+
+// NewContext returns a copy of the context holding the result.
+func NewContext(ctx context.Context, result *Result) context.Context {
+	return context.WithValue(ctx, resultKey{}, result)
+}
+
+// FromContext returns the result stored in the context by the middleware, if any.
+// Handlers running in the same process after the middleware can use it instead of parsing the headers.
+func FromContext(ctx context.Context) (*Result, bool) {
+	result, ok := ctx.Value(resultKey{}).(*Result)
+	return result, ok
+}
@@ -0,0 +1,428 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	// RuleActionAllow stops the evaluation of the rules and forwards the request.
+	RuleActionAllow = "allow"
+	// RuleActionDeny answers the request with the rule's status code, 403 by default.
+	RuleActionDeny = "deny"
+	// RuleActionTag sets the rule's header on the request and continues with the next rule.
+	RuleActionTag = "tag"
+	// RuleActionRedirect redirects the request to the rule's location, with the rule's status code, 302 by default.
+	RuleActionRedirect = "redirect"
+	// RuleActionSkip stops the evaluation of the rules and forwards the request without geo headers.
+	RuleActionSkip = "skip"
+
+	// AnonymityAnonymousProxy matches IPs flagged as anonymous proxies.
+	AnonymityAnonymousProxy = "anonymousProxy"
+	// AnonymitySatelliteProvider matches IPs flagged as satellite providers.
+	AnonymitySatelliteProvider = "satelliteProvider"
+)
+
+// RuleConfig a geo policy rule. A rule matches a request when every condition it sets matches.
+// Conditions with a list of values match when any of the values matches.
+type RuleConfig struct {
+	// Name of the rule, used in logs. Defaults to the rule's position.
+	Name string `json:"name,omitempty"`
+
+	// Countries are country codes or country groups, such as "@EU".
+	Countries []string `json:"countries,omitempty"`
+	// NotCountries are country codes or country groups the client must not be in.
+	NotCountries []string `json:"notCountries,omitempty"`
+	// Regions are region codes, such as "BY", or country and region codes, such as "DE-BY".
+	// With IP2Location databases they are region names, such as "Bayern" or "DE-Bayern", in any case.
+	Regions []string `json:"regions,omitempty"`
+	// ASNs are autonomous system numbers, such as "AS12345" or "12345".
+	ASNs []string `json:"asns,omitempty"` //nolint:tagliatelle
+	// CIDRs are IPs or CIDRs the client IP must be in.
+	CIDRs []string `json:"cidrs,omitempty"` //nolint:tagliatelle
+	// Anonymity flags, "anonymousProxy" or "satelliteProvider", the client IP must have.
+	Anonymity []string `json:"anonymity,omitempty"`
+	// Geofences containing the client.
+	Geofences []string `json:"geofences,omitempty"`
+	// Hosts of the request. A leading "*." matches any subdomain.
+	Hosts []string `json:"hosts,omitempty"`
+	// PathPrefixes of the request path, matched by segment, so "/admin" matches "/admin/users" but not "/administrator".
+	PathPrefixes []string `json:"pathPrefixes,omitempty"`
+	// PathRegex the request path must match.
+	PathRegex string `json:"pathRegex,omitempty"`
+	// Methods of the request.
+	Methods []string `json:"methods,omitempty"`
+	// Headers maps header names to a regular expression their value must match.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Action is one of "allow", "deny", "tag", "redirect" or "skip".
+	Action string `json:"action,omitempty"`
+	// StatusCode of "deny" and "redirect" actions.
+	StatusCode int `json:"statusCode,omitempty"`
+	// Header and Value set by "tag" actions.
+	Header string `json:"header,omitempty"`
+	Value  string `json:"value,omitempty"`
+	// Location of "redirect" actions. Accepts the same placeholders as redirects.
+	Location string `json:"location,omitempty"`
+	// Mode overrides the middleware's mode for this rule, either "enforce" or "report".
+	Mode string `json:"mode,omitempty"`
+}
+
+// rule a compiled RuleConfig.
+type rule struct {
+	name string
+
+	countries    map[string]bool
+	notCountries map[string]bool
+	regions      map[string]bool
+	asns         map[string]bool
+	cidrs        []*net.IPNet
+	anonymity    []string
+	geofences    map[string]bool
+	hosts        []string
+	pathPrefixes []string
+	pathRegex    *regexp.Regexp
+	methods      map[string]bool
+	headers      map[string]*regexp.Regexp
+
+	action   string
+	status   int
+	header   string
+	value    string
+	location string
+	mode     string
+}
+
+// compileRules validates and compiles the rules.
+func compileRules(configs []RuleConfig, groups *countryGroups, geofences *geofenceIndex) ([]*rule, error) {
+	rules := make([]*rule, 0, len(configs))
+	for i, cfg := range configs {
+		name := cfg.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		compiled, err := compileRule(name, cfg, groups, geofences)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %s: %w", name, err)
+		}
+		rules = append(rules, compiled)
+	}
+	return rules, nil
+}
+
+// countrySet expands country codes and groups into a set. Returns nil for an empty list.
+func countrySet(groups *countryGroups, values []string) (map[string]bool, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	set := map[string]bool{}
+	for _, value := range values {
+		codes, err := groups.expand(value)
+		if err != nil {
+			return nil, err
+		}
+		for _, code := range codes {
+			set[code] = true
+		}
+	}
+	return set, nil
+}
+
+// upperSet uppercases the values into a set. Returns nil for an empty list.
+func upperSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := map[string]bool{}
+	for _, value := range values {
+		set[strings.ToUpper(strings.TrimSpace(value))] = true
+	}
+	return set
+}
+
+// parseCIDRs parses a list of IPs and CIDRs. IPs are turned into single address CIDRs.
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	cidrs := make([]*net.IPNet, 0, len(values))
+	for _, v := range values {
+		if net.ParseIP(v) != nil {
+			if strings.Contains(v, ":") {
+				v += "/128"
+			} else {
+				v += "/32"
+			}
+		}
+		_, cidr, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR: cidr=%s", v)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs, nil
+}
+
+// compileRule validates and compiles a single rule.
+func compileRule(name string, cfg RuleConfig, groups *countryGroups, geofences *geofenceIndex) (*rule, error) { //nolint:gocognit,cyclop,funlen
+	r := &rule{
+		name:         name,
+		regions:      upperSet(cfg.Regions),
+		methods:      upperSet(cfg.Methods),
+		pathPrefixes: cfg.PathPrefixes,
+		action:       cfg.Action,
+		status:       cfg.StatusCode,
+		header:       cfg.Header,
+		value:        cfg.Value,
+		location:     cfg.Location,
+		mode:         cfg.Mode,
+	}
+
+	if cfg.Mode != "" && cfg.Mode != ModeEnforce && cfg.Mode != ModeReport {
+		return nil, fmt.Errorf("invalid mode: mode=%s", cfg.Mode)
+	}
+
+	var err error
+	if r.countries, err = countrySet(groups, cfg.Countries); err != nil {
+		return nil, err
+	}
+	if r.notCountries, err = countrySet(groups, cfg.NotCountries); err != nil {
+		return nil, err
+	}
+
+	if len(cfg.ASNs) > 0 {
+		r.asns = map[string]bool{}
+		for _, asn := range cfg.ASNs {
+			number := normalizeRateLimitValue(RateLimitKeyASN, asn)
+			for _, c := range number {
+				if c < '0' || c > '9' {
+					return nil, fmt.Errorf("invalid ASN: asn=%s", asn)
+				}
+			}
+			if number == "" {
+				return nil, fmt.Errorf("invalid ASN: asn=%s", asn)
+			}
+			r.asns[strings.TrimLeft(number, "0")] = true
+		}
+	}
+
+	if r.cidrs, err = parseCIDRs(cfg.CIDRs); err != nil {
+		return nil, err
+	}
+
+	for _, flag := range cfg.Anonymity {
+		if flag != AnonymityAnonymousProxy && flag != AnonymitySatelliteProvider {
+			return nil, fmt.Errorf("invalid anonymity flag: flag=%s", flag)
+		}
+		r.anonymity = append(r.anonymity, flag)
+	}
+
+	if len(cfg.Geofences) > 0 {
+		if r.geofences, err = geofenceSet(geofences, cfg.Geofences); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, host := range cfg.Hosts {
+		r.hosts = append(r.hosts, strings.ToLower(host))
+	}
+
+	if cfg.PathRegex != "" {
+		if r.pathRegex, err = regexp.Compile(cfg.PathRegex); err != nil {
+			return nil, fmt.Errorf("invalid path regex: %w", err)
+		}
+	}
+
+	if len(cfg.Headers) > 0 {
+		r.headers = map[string]*regexp.Regexp{}
+		for header, pattern := range cfg.Headers {
+			if r.headers[http.CanonicalHeaderKey(header)], err = regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("invalid regex of header %s: %w", header, err)
+			}
+		}
+	}
+
+	switch cfg.Action {
+	case RuleActionAllow, RuleActionSkip:
+	case RuleActionDeny:
+		if r.status == 0 {
+			r.status = http.StatusForbidden
+		}
+		if r.status < 400 || r.status > 599 {
+			return nil, fmt.Errorf("invalid deny status code: code=%d", r.status)
+		}
+	case RuleActionRedirect:
+		if r.location == "" {
+			return nil, fmt.Errorf("redirect requires a location")
+		}
+		if r.status == 0 {
+			r.status = http.StatusFound
+		}
+		if r.status < 300 || r.status > 399 {
+			return nil, fmt.Errorf("invalid redirect status code: code=%d", r.status)
+		}
+	case RuleActionTag:
+		if r.header == "" {
+			return nil, fmt.Errorf("tag requires a header")
+		}
+	case "":
+		return nil, fmt.Errorf("missing action")
+	default:
+		return nil, fmt.Errorf("unknown action: action=%s", cfg.Action)
+	}
+
+	return r, nil
+}
+
+// hostMatches checks if the request host matches a configured host.
+func hostMatches(host, pattern string) bool {
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return host == pattern
+}
+
+// matches checks every condition of the rule. The result may be nil if the lookup failed.
+func (r *rule) matches(req *http.Request, ip net.IP, result *GeoIPResult) bool { //nolint:gocognit,cyclop
+	if result == nil {
+		result = &GeoIPResult{}
+	}
+
+	if r.countries != nil && !r.countries[result.countryCode] {
+		return false
+	}
+	if r.notCountries != nil && (!known(result.countryCode) || r.notCountries[result.countryCode]) {
+		return false
+	}
+	if r.regions != nil {
+		// Region names, as set by IP2Location, are matched whatever their case.
+		region := strings.ToUpper(result.region)
+		if !r.regions[region] && !r.regions[result.countryCode+"-"+region] {
+			return false
+		}
+	}
+	if r.asns != nil && !r.asns[result.asn] {
+		return false
+	}
+	if len(r.cidrs) > 0 {
+		found := false
+		for _, cidr := range r.cidrs {
+			found = found || cidr.Contains(ip)
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.anonymity) > 0 {
+		found := false
+		for _, flag := range r.anonymity {
+			found = found || (flag == AnonymityAnonymousProxy && result.anonymousProxy) ||
+				(flag == AnonymitySatelliteProvider && result.satelliteProvider)
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.geofences != nil {
+		found := false
+		for _, name := range result.geofences {
+			found = found || r.geofences[name]
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.hosts) > 0 {
+		host := strings.ToLower(req.Host)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		found := false
+		for _, pattern := range r.hosts {
+			found = found || hostMatches(host, pattern)
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.pathPrefixes) > 0 {
+		found := false
+		for _, prefix := range r.pathPrefixes {
+			found = found || pathHasPrefix(req.URL.Path, prefix)
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.pathRegex != nil && !r.pathRegex.MatchString(req.URL.Path) {
+		return false
+	}
+	if r.methods != nil && !r.methods[req.Method] {
+		return false
+	}
+	for header, pattern := range r.headers {
+		if !pattern.MatchString(req.Header.Get(header)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ruleOutcome the result of evaluating the rules against a request.
+type ruleOutcome struct {
+	// rule that ended the evaluation, nil if no terminal rule matched.
+	rule *rule
+	// decision to send instead of forwarding the request, if any. It is always enforced.
+	decision *decision
+	// skip forwards the request without geo headers.
+	skip bool
+}
+
+// evaluateRules applies the rules in order until a terminal action matches.
+// Deny and redirect rules that are only reported, or bypassed, are recorded and the evaluation goes on,
+// so trialing a rule never turns off the rules after it.
+func (mw *TraefikGeoIP) evaluateRules(req *http.Request, ip net.IP, result *GeoIPResult, bypassID string) ruleOutcome {
+	for _, r := range mw.rules {
+		if !r.matches(req, ip, result) {
+			continue
+		}
+
+		switch r.action {
+		case RuleActionTag:
+			req.Header.Set(r.header, r.value)
+			continue
+		case RuleActionAllow:
+			return ruleOutcome{rule: r}
+		case RuleActionSkip:
+			return ruleOutcome{rule: r, skip: true}
+		case RuleActionDeny:
+			dec := &decision{status: r.status, action: decisionDeny, rule: r.name, mode: r.mode}
+			if mw.enforce(req, dec, bypassID) {
+				return ruleOutcome{rule: r, decision: dec}
+			}
+		case RuleActionRedirect:
+			countryCode := ""
+			if result != nil && known(result.countryCode) {
+				countryCode = result.countryCode
+			}
+			target := redirectTarget(req, r.location, countryCode)
+			if target == "" {
+				// Already on the target, stop here instead of looping.
+				return ruleOutcome{rule: r}
+			}
+			dec := &decision{
+				status:   r.status,
+				action:   decisionRedirect,
+				rule:     r.name,
+				mode:     r.mode,
+				location: target,
+			}
+			if mw.enforce(req, dec, bypassID) {
+				return ruleOutcome{rule: r, decision: dec}
+			}
+		}
+	}
+
+	return ruleOutcome{}
+}
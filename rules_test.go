@@ -0,0 +1,136 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func russianResult(asn string) *GeoIPResult {
+	return &GeoIPResult{
+		country: "Russia", countryCode: "RU", region: Unknown, city: Unknown,
+		latitude: Unknown, longitude: Unknown, geohash: Unknown, asn: asn,
+	}
+}
+
+func serveRule(t *testing.T, cfg *Config, result *GeoIPResult, method, url string) (*httptest.ResponseRecorder, *http.Request) {
+	t.Helper()
+
+	var forwarded *http.Request
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) { forwarded = req })
+	instance, err := newMiddleware(next, cfg, "traefik_geoip", staticLookup(result))
+	if err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+
+	req := httptest.NewRequest(method, url, nil)
+	req.RemoteAddr = "188.193.88.199:9999"
+	recorder := httptest.NewRecorder()
+	instance.ServeHTTP(recorder, req)
+	return recorder, forwarded
+}
+
+func TestRulesDenyWithException(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Rules = []RuleConfig{
+		{Name: "status-probe", Countries: []string{"RU"}, ASNs: []string{"AS12345"}, PathPrefixes: []string{"/status"}, Action: RuleActionAllow},
+		{Name: "deny-ru", Countries: []string{"RU"}, Action: RuleActionDeny, StatusCode: http.StatusUnavailableForLegalReasons},
+	}
+
+	recorder, _ := serveRule(t, cfg, russianResult("12345"), http.MethodGet, "http://example.com/status")
+	assertStatus(t, recorder, http.StatusOK)
+
+	recorder, _ = serveRule(t, cfg, russianResult("12345"), http.MethodGet, "http://example.com/login")
+	assertStatus(t, recorder, http.StatusUnavailableForLegalReasons)
+
+	recorder, _ = serveRule(t, cfg, russianResult("666"), http.MethodGet, "http://example.com/status")
+	assertStatus(t, recorder, http.StatusUnavailableForLegalReasons)
+
+	recorder, _ = serveRule(t, cfg, munichResult(), http.MethodGet, "http://example.com/login")
+	assertStatus(t, recorder, http.StatusOK)
+}
+
+func TestRulesPathPrefixSegments(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Rules = []RuleConfig{{Name: "deny-admin", PathPrefixes: []string{"/admin"}, Action: RuleActionDeny}}
+
+	for url, expected := range map[string]int{
+		"http://example.com/admin":          http.StatusForbidden,
+		"http://example.com/admin/":         http.StatusForbidden,
+		"http://example.com/admin/users":    http.StatusForbidden,
+		"http://example.com/administrator":  http.StatusOK,
+		"http://example.com/admin-public/x": http.StatusOK,
+	} {
+		recorder, _ := serveRule(t, cfg, munichResult(), http.MethodGet, url)
+		if recorder.Code != expected {
+			t.Fatalf("expected %d for %s, got %d", expected, url, recorder.Code)
+		}
+	}
+}
+
+func TestRulesTagAndSkip(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Rules = []RuleConfig{
+		{NotCountries: []string{"@EU"}, Anonymity: []string{AnonymityAnonymousProxy}, Action: RuleActionTag, Header: "X-Suspicious", Value: "1"},
+		{Methods: []string{"post"}, Hosts: []string{"*.internal.example.com"}, Action: RuleActionSkip},
+	}
+
+	anonymous := russianResult("")
+	anonymous.anonymousProxy = true
+	recorder, forwarded := serveRule(t, cfg, anonymous, http.MethodGet, "http://example.com/")
+	assertStatus(t, recorder, http.StatusOK)
+	if forwarded.Header.Get("X-Suspicious") != "1" || forwarded.Header.Get(CountryCodeHeader) != "RU" {
+		t.Fatalf("anonymous IPs outside the EU must be tagged and enriched")
+	}
+
+	anonymous.countryCode = "DE"
+	_, forwarded = serveRule(t, cfg, anonymous, http.MethodGet, "http://example.com/")
+	if forwarded.Header.Get("X-Suspicious") != "" {
+		t.Fatalf("anonymous IPs inside the EU must not be tagged")
+	}
+
+	_, forwarded = serveRule(t, cfg, munichResult(), http.MethodPost, "http://api.internal.example.com:8080/")
+	if forwarded.Header.Get(CountryCodeHeader) != "" {
+		t.Fatalf("skipped requests must not be enriched")
+	}
+}
+
+func TestRulesRedirect(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Rules = []RuleConfig{
+		{Countries: []string{"DE"}, PathRegex: "^/(shop|cart)", Action: RuleActionRedirect, Location: "/{cc}{path}"},
+	}
+
+	recorder, _ := serveRule(t, cfg, munichResult(), http.MethodGet, "http://example.com/shop/1")
+	assertStatus(t, recorder, http.StatusFound)
+	if recorder.Header().Get("Location") != "/de/shop/1" {
+		t.Fatalf("invalid redirect '%s'", recorder.Header().Get("Location"))
+	}
+}
+
+func TestRulesValidation(t *testing.T) {
+	invalid := []RuleConfig{
+		{Action: "block"},
+		{Countries: []string{"DE"}},
+		{Countries: []string{"@nowhere"}, Action: RuleActionDeny},
+		{ASNs: []string{"ASX"}, Action: RuleActionDeny},
+		{CIDRs: []string{"10.0.0.0/33"}, Action: RuleActionDeny},
+		{Anonymity: []string{"tor"}, Action: RuleActionDeny},
+		{Geofences: []string{"missing"}, Action: RuleActionDeny},
+		{PathRegex: "(", Action: RuleActionDeny},
+		{Headers: map[string]string{"User-Agent": "["}, Action: RuleActionDeny},
+		{Action: RuleActionDeny, StatusCode: http.StatusOK},
+		{Action: RuleActionRedirect},
+		{Action: RuleActionTag},
+	}
+	for _, cfg := range invalid {
+		if _, err := compileRules([]RuleConfig{cfg}, builtinGroups(t), &geofenceIndex{}); err == nil {
+			t.Fatalf("Must fail on invalid rule %+v", cfg)
+		}
+	}
+
+	_, err := compileRules([]RuleConfig{{Name: "broken", Action: "block"}}, builtinGroups(t), &geofenceIndex{})
+	if err == nil || err.Error() != "invalid rule broken: unknown action: action=block" {
+		t.Fatalf("invalid error message: %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+// Package signature signs and verifies the geo headers set by the traefik_geoip middleware,
+// so backends can check that the headers of a request really come from the middleware.
+//
+// The GeoIP-Signature header holds the key ID, the signing time, the list of signed headers and an HMAC-SHA256
+// over the signed headers, the time and the request's host and path:
+//
+//	GeoIP-Signature: keyId=2025-01,t=1735689600,h=geoip-country;geoip-country-code,sig=<base64url>
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Header is the header holding the signature.
+	Header = "GeoIP-Signature"
+	// DefaultMaxAge is the default maximum age of a signature accepted by a Verifier.
+	DefaultMaxAge = 5 * time.Minute
+
+	// geoHeaderPrefix is the canonical prefix of the geo headers.
+	geoHeaderPrefix = "Geoip-"
+	// version prefixes the canonical string, so the format can evolve.
+	version = "v1"
+)
+
+var (
+	// ErrMissing the request has no signature.
+	ErrMissing = errors.New("missing signature")
+	// ErrMalformed the signature can not be parsed.
+	ErrMalformed = errors.New("malformed signature")
+	// ErrUnknownKey the signature was made with a key the verifier does not know.
+	ErrUnknownKey = errors.New("unknown signature key")
+	// ErrExpired the signature is older, or further in the future, than the verifier's maximum age.
+	ErrExpired = errors.New("expired signature")
+	// ErrUnsignedHeader the request has a geo header that is not covered by the signature.
+	ErrUnsignedHeader = errors.New("unsigned geo header")
+	// ErrInvalid the signature does not match the request.
+	ErrInvalid = errors.New("invalid signature")
+)
+
+// Key a signing key, identified by its ID so keys can be rotated.
+type Key struct {
+	ID     string
+	Secret string
+}
+
+// GeoHeaders returns the canonical names of the geo headers of a request, sorted, without the signature header.
+func GeoHeaders(header http.Header) []string {
+	names := []string{}
+	for name := range header {
+		if strings.HasPrefix(name, geoHeaderPrefix) && name != http.CanonicalHeaderKey(Header) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// canonical builds the signed string.
+func canonical(timestamp int64, host, path string, names []string, header http.Header) string {
+	b := strings.Builder{}
+	b.WriteString(version + "\n")
+	b.WriteString(strconv.FormatInt(timestamp, 10) + "\n")
+	b.WriteString(strings.ToLower(host) + "\n")
+	b.WriteString(path + "\n")
+	for _, name := range names {
+		b.WriteString(strings.ToLower(name) + ":" + strings.Join(header.Values(name), ", ") + "\n")
+	}
+	return b.String()
+}
+
+// mac computes the HMAC-SHA256 of the canonical string.
+func mac(secret, value string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Sign signs the named headers of the request with the key, and sets the signature header.
+func Sign(req *http.Request, key Key, names []string, now time.Time) {
+	timestamp := now.Unix()
+
+	lowered := make([]string, 0, len(names))
+	for _, name := range names {
+		lowered = append(lowered, strings.ToLower(name))
+	}
+
+	sig := mac(key.Secret, canonical(timestamp, req.Host, req.URL.Path, names, req.Header))
+	req.Header.Set(Header, "keyId="+key.ID+",t="+strconv.FormatInt(timestamp, 10)+",h="+strings.Join(lowered, ";")+",sig="+sig)
+}
+
+// parsed a parsed signature header.
+type parsed struct {
+	keyID     string
+	timestamp int64
+	names     []string
+	sig       string
+}
+
+// parse parses a signature header.
+func parse(value string) (*parsed, error) {
+	p := &parsed{}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ",") {
+		key, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || seen[key] {
+			return nil, ErrMalformed
+		}
+		seen[key] = true
+
+		switch key {
+		case "keyId":
+			p.keyID = v
+		case "t":
+			timestamp, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, ErrMalformed
+			}
+			p.timestamp = timestamp
+		case "h":
+			if v != "" {
+				p.names = strings.Split(v, ";")
+			}
+		case "sig":
+			p.sig = v
+		default:
+			return nil, ErrMalformed
+		}
+	}
+	if !seen["keyId"] || !seen["t"] || !seen["h"] || !seen["sig"] {
+		return nil, ErrMalformed
+	}
+	return p, nil
+}
+
+// Verifier verifies the signature of requests.
+type Verifier struct {
+	// Keys maps key IDs to secrets. Keep the previous key during a rotation.
+	Keys map[string]string
+	// MaxAge is the maximum age of accepted signatures.
+	MaxAge time.Duration
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewVerifier creates a verifier accepting the given keys, with the default maximum age.
+func NewVerifier(keys map[string]string) *Verifier {
+	return &Verifier{Keys: keys, MaxAge: DefaultMaxAge, Now: time.Now}
+}
+
+// Verify checks that the geo headers of the request were signed by the middleware.
+// Every geo header of the request must be covered by the signature.
+func (v *Verifier) Verify(req *http.Request) error {
+	value := req.Header.Get(Header)
+	if value == "" {
+		return ErrMissing
+	}
+
+	p, err := parse(value)
+	if err != nil {
+		return err
+	}
+
+	secret, ok := v.Keys[p.keyID]
+	if !ok {
+		return ErrUnknownKey
+	}
+
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	age := now().Sub(time.Unix(p.timestamp, 0))
+	if age > v.MaxAge || age < -v.MaxAge {
+		return ErrExpired
+	}
+
+	signed := map[string]bool{}
+	for _, name := range p.names {
+		signed[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, name := range GeoHeaders(req.Header) {
+		if !signed[name] {
+			return ErrUnsignedHeader
+		}
+	}
+
+	expected := mac(secret, canonical(p.timestamp, req.Host, req.URL.Path, p.names, req.Header))
+	if !hmac.Equal([]byte(expected), []byte(p.sig)) {
+		return ErrInvalid
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package signature
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func signedRequest(t *testing.T, now time.Time) *http.Request {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/shop?q=1", nil)
+	req.Header.Set("GeoIP-Country-Code", "DE")
+	req.Header.Add("GeoIP-Policy-Decision", "would-deny; rule=a")
+	req.Header.Add("GeoIP-Policy-Decision", "would-deny; rule=b")
+	req.Header.Set("X-Real-Ip", "188.193.88.199")
+	Sign(req, Key{ID: "2025-01", Secret: "s3cret"}, append(GeoHeaders(req.Header), "X-Real-Ip"), now)
+	return req
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1735689600, 0)
+	verifier := NewVerifier(map[string]string{"2024-12": "old", "2025-01": "s3cret"})
+	verifier.Now = func() time.Time { return now }
+
+	req := signedRequest(t, now)
+	expected := "keyId=2025-01,t=1735689600,h=geoip-country-code;geoip-policy-decision;x-real-ip,sig="
+	if value := req.Header.Get(Header); len(value) <= len(expected) || value[:len(expected)] != expected {
+		t.Fatalf("invalid signature header '%s'", value)
+	}
+	if err := verifier.Verify(req); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(req *http.Request)
+		err    error
+	}{
+		{"missing", func(req *http.Request) { req.Header.Del(Header) }, ErrMissing},
+		{"malformed", func(req *http.Request) { req.Header.Set(Header, "keyId=2025-01,t=x,h=,sig=") }, ErrMalformed},
+		{"unknown key", func(req *http.Request) { verifier.Keys = map[string]string{"2024-12": "old"} }, ErrUnknownKey},
+		{"expired", func(req *http.Request) { verifier.Now = func() time.Time { return now.Add(time.Hour) } }, ErrExpired},
+		{"added header", func(req *http.Request) { req.Header.Set("GeoIP-City", "Munich") }, ErrUnsignedHeader},
+		{"changed header", func(req *http.Request) { req.Header.Set("GeoIP-Country-Code", "RU") }, ErrInvalid},
+		{"changed real IP", func(req *http.Request) { req.Header.Set("X-Real-Ip", "1.2.3.4") }, ErrInvalid},
+		{"changed host", func(req *http.Request) { req.Host = "evil.example.com" }, ErrInvalid},
+		{"changed path", func(req *http.Request) { req.URL.Path = "/admin" }, ErrInvalid},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verifier = NewVerifier(map[string]string{"2025-01": "s3cret"})
+			verifier.Now = func() time.Time { return now }
+			req := signedRequest(t, now)
+			test.mutate(req)
+			if err := verifier.Verify(req); !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
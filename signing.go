@@ -0,0 +1,83 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Maronato/traefik_geoip/signature"
+)
+
+// SignatureHeader header with the signature of the geo headers.
+const SignatureHeader = signature.Header
+
+// SignatureConfig configures the signature of the geo headers.
+type SignatureConfig struct {
+	// KeyID identifies the key, so backends can accept several keys while it is rotated.
+	KeyID string `json:"keyId,omitempty"`
+	// Secret signs the headers. Signing is disabled when empty.
+	Secret string `json:"secret,omitempty"`
+}
+
+// signer signs the geo headers of forwarded requests.
+type signer struct {
+	key  signature.Key
+	keep map[string]bool
+	now  func() time.Time
+}
+
+// newSigner creates the signer from its config. Returns nil when signing is disabled.
+// The kept headers are inputs of the middleware that share the geo headers' prefix, and must not be stripped.
+func newSigner(cfg SignatureConfig, keep ...string) (*signer, error) {
+	if cfg.Secret == "" {
+		return nil, nil //nolint:nilnil
+	}
+	if cfg.KeyID == "" || strings.ContainsAny(cfg.KeyID, ",=") {
+		return nil, fmt.Errorf("invalid signature key ID: keyId=%s", cfg.KeyID)
+	}
+
+	s := &signer{
+		key:  signature.Key{ID: cfg.KeyID, Secret: cfg.Secret},
+		keep: map[string]bool{},
+		now:  time.Now,
+	}
+	for _, name := range keep {
+		if name != "" {
+			s.keep[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+	return s, nil
+}
+
+// stripGeoHeaders removes the geo headers sent by the client, so only headers set by the middleware are signed.
+func (mw *TraefikGeoIP) stripGeoHeaders(req *http.Request) {
+	s := mw.signer
+	if s == nil {
+		return
+	}
+
+	for _, name := range signature.GeoHeaders(req.Header) {
+		if !s.keep[name] {
+			req.Header.Del(name)
+		}
+	}
+	req.Header.Del(SignatureHeader)
+	if mw.setRealIP {
+		req.Header.Del("X-Real-Ip")
+	}
+}
+
+// sign signs the geo headers of the request, and the real IP header when it is set by the middleware.
+func (mw *TraefikGeoIP) sign(req *http.Request) {
+	s := mw.signer
+	if s == nil {
+		return
+	}
+
+	names := signature.GeoHeaders(req.Header)
+	if mw.setRealIP && req.Header.Get("X-Real-Ip") != "" {
+		names = append(names, "X-Real-Ip")
+	}
+	signature.Sign(req, s.key, names, s.now())
+}
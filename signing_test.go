@@ -0,0 +1,44 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Maronato/traefik_geoip/signature"
+)
+
+func TestSignedHeaders(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.SetRealIP = true
+	cfg.Signature = SignatureConfig{KeyID: "2025-01", Secret: "s3cret"}
+	cfg.Bypass.Secret = "bypass"
+	instance := newTestMiddleware(t, cfg, munichResult())
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/shop", nil)
+	req.RemoteAddr = "188.193.88.199:9999"
+	req.Header.Set("GeoIP-Nearest", "spoofed")
+	req.Header.Set(SignatureHeader, "spoofed")
+	req.Header.Set(defaultBypassTokenHeader, "not-a-token")
+	instance.ServeHTTP(httptest.NewRecorder(), req)
+
+	if req.Header.Get("GeoIP-Nearest") != "" {
+		t.Fatalf("client geo headers must be removed")
+	}
+	if req.Header.Get(CountryCodeHeader) != "DE" {
+		t.Fatalf("signed requests must be enriched")
+	}
+	if err := signature.NewVerifier(map[string]string{"2025-01": "s3cret"}).Verify(req); err != nil {
+		t.Fatalf("signature rejected: %v", err)
+	}
+
+	req.Header.Set("X-Real-Ip", "1.2.3.4")
+	if err := signature.NewVerifier(map[string]string{"2025-01": "s3cret"}).Verify(req); err == nil {
+		t.Fatalf("real IP must be signed")
+	}
+
+	cfg.Signature.KeyID = ""
+	if _, err := newMiddleware(nil, cfg, "traefik_geoip", staticLookup(munichResult())); err == nil {
+		t.Fatalf("Must fail without key ID")
+	}
+}
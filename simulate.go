@@ -0,0 +1,102 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// SimulatedHeader marks requests and responses whose client IP was simulated, with the simulated IP.
+const SimulatedHeader = "GeoIP-Simulated"
+
+// simulation lets trusted clients choose the IP their requests are looked up with.
+type simulation struct {
+	header  string
+	trusted []*net.IPNet
+}
+
+// newSimulation creates the simulation from its config. Returns nil when simulation is disabled.
+func newSimulation(header string, trustedIPs []string) (*simulation, error) {
+	if header == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	trusted, err := parseCIDRs(trustedIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &simulation{header: header, trusted: trusted}, nil
+}
+
+// isTrusted checks if the request comes directly from a trusted client.
+func (s *simulation) isTrusted(req *http.Request) bool {
+	return fromTrustedAddr(req, s.trusted)
+}
+
+// fromTrustedAddr checks if the request's remote address is in one of the trusted CIDRs.
+// Only the remote address is checked, as forwarded headers are under the client's control.
+func fromTrustedAddr(req *http.Request, trusted []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, cidr := range trusted {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// simulatedIP returns the IP the request asks to be treated as coming from, or nil.
+// The request is marked with the SimulatedHeader when the simulation is honored.
+func (mw *TraefikGeoIP) simulatedIP(req *http.Request) net.IP {
+	// Never trust a simulation mark sent by the client.
+	req.Header.Del(SimulatedHeader)
+
+	s := mw.simulation
+	if s == nil {
+		return nil
+	}
+
+	value := strings.TrimSpace(req.Header.Get(s.header))
+	if value == "" {
+		return nil
+	}
+	req.Header.Del(s.header)
+
+	if !s.isTrusted(req) {
+		if mw.debug {
+			log.Printf("[geoip] IP simulation from untrusted client: remote=%s, name=%s", req.RemoteAddr, mw.name)
+		}
+		return nil
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		if mw.debug {
+			log.Printf("[geoip] unable to parse simulated IP: ip=%s, name=%s", value, mw.name)
+		}
+		return nil
+	}
+
+	mw.metrics.inc("simulated")
+	log.Printf("[geoip] simulating IP: ip=%s, remote=%s, host=%s, path=%s, name=%s",
+		ip, req.RemoteAddr, req.Host, req.URL.Path, mw.name)
+	req.Header.Set(SimulatedHeader, ip.String())
+	return ip
+}
+
+// markSimulated copies the simulation mark of the request to the response.
+func markSimulated(rw http.ResponseWriter, req *http.Request) {
+	if ip := req.Header.Get(SimulatedHeader); ip != "" {
+		rw.Header().Set(SimulatedHeader, ip)
+	}
+}
@@ -0,0 +1,70 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSimulateIP(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.SimulateIPHeader = "X-Simulate-IP"
+	cfg.SimulateTrustedIPs = []string{"10.0.0.0/8"}
+	cfg.ExcludeIPs = []string{"81.2.69.0/24"}
+	cfg.Rules = []RuleConfig{{Name: "deny-gb", Countries: []string{"GB"}, Action: RuleActionDeny}}
+
+	var looked net.IP
+	lookup := func(ip net.IP) (*GeoIPResult, error) {
+		looked = ip
+		result := munichResult()
+		if ip.Equal(net.ParseIP("81.2.69.160")) {
+			result.country, result.countryCode = "United Kingdom", "GB"
+		}
+		return result, nil
+	}
+	instance, err := newMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}), cfg, "traefik_geoip", lookup)
+	if err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+
+	serve := func(remoteAddr string) (*httptest.ResponseRecorder, *http.Request) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", "188.193.88.199")
+		req.Header.Set("X-Simulate-IP", "81.2.69.160")
+		req.Header.Set(SimulatedHeader, "spoofed")
+		recorder := httptest.NewRecorder()
+		instance.ServeHTTP(recorder, req)
+		return recorder, req
+	}
+
+	recorder, req := serve("10.1.2.3:9999")
+	assertStatus(t, recorder, http.StatusForbidden)
+	if !looked.Equal(net.ParseIP("81.2.69.160")) {
+		t.Fatalf("simulated IP must be looked up, got %v", looked)
+	}
+	if recorder.Header().Get(SimulatedHeader) != "81.2.69.160" || req.Header.Get(SimulatedHeader) != "81.2.69.160" {
+		t.Fatalf("simulated requests must be marked")
+	}
+
+	recorder, req = serve("192.0.2.1:9999")
+	assertStatus(t, recorder, http.StatusOK)
+	if !looked.Equal(net.ParseIP("188.193.88.199")) {
+		t.Fatalf("untrusted clients must not simulate IPs, got %v", looked)
+	}
+	if recorder.Header().Get(SimulatedHeader) != "" || req.Header.Get(SimulatedHeader) != "" {
+		t.Fatalf("client simulation marks must be removed")
+	}
+
+	counters := instance.Metrics()
+	if counters["simulated"] != 1 || counters["deny;rule=deny-gb;simulated"] != 1 || counters["deny;rule=deny-gb"] != 0 {
+		t.Fatalf("invalid metrics %v", counters)
+	}
+
+	cfg.SimulateTrustedIPs = []string{"nope"}
+	if _, err := newMiddleware(nil, cfg, "traefik_geoip", lookup); err == nil {
+		t.Fatalf("Must fail on invalid trusted IPs")
+	}
+}
@@ -0,0 +1,237 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// ImpossibleTravelHeader header set to "true" when the session moved faster than allowed.
+	ImpossibleTravelHeader = "GeoIP-Impossible-Travel"
+	// TravelFromHeader header with the previous country code of the session.
+	TravelFromHeader = "GeoIP-Travel-From"
+	// TravelDistanceHeader header with the distance travelled by the session, in kilometers.
+	TravelDistanceHeader = "GeoIP-Travel-Distance-Km"
+	// TravelElapsedHeader header with the seconds elapsed since the previous location of the session.
+	TravelElapsedHeader = "GeoIP-Travel-Elapsed"
+
+	// TravelActionDeny denies requests of sessions that moved faster than allowed.
+	TravelActionDeny = "deny"
+
+	// defaultTravelCookie default name of the travel cookie.
+	defaultTravelCookie = "geoip_travel"
+	// defaultTravelCookieMaxAge default lifetime of the travel cookie.
+	defaultTravelCookieMaxAge = 24 * time.Hour
+	// defaultTravelMaxSpeedKmh default speed above which travel is impossible, a bit faster than a plane.
+	defaultTravelMaxSpeedKmh = 1000
+	// defaultTravelMinDistanceKm default distance under which travel is ignored, as IP locations are imprecise.
+	defaultTravelMinDistanceKm = 100
+	// travelRule name of the travel policy in decisions.
+	travelRule = "impossibleTravel"
+)
+
+var errInvalidTravelCookie = errors.New("invalid travel cookie")
+
+// TravelConfig configures the detection of sessions jumping between distant locations.
+type TravelConfig struct {
+	// Secret encrypts the travel cookie. Detection is disabled when empty.
+	Secret string `json:"secret,omitempty"`
+	// MaxSpeedKmh is the speed above which travel is impossible.
+	MaxSpeedKmh float64 `json:"maxSpeedKmh,omitempty"`
+	// MinDistanceKm is the distance under which travel is ignored.
+	MinDistanceKm float64 `json:"minDistanceKm,omitempty"`
+	// CookieName is the name of the travel cookie.
+	CookieName string `json:"cookieName,omitempty"`
+	// CookieMaxAge is the lifetime of the travel cookie, such as "24h".
+	CookieMaxAge string `json:"cookieMaxAge,omitempty"`
+	// Action taken on impossible travel. Requests are only marked when empty, and denied with "deny".
+	Action string `json:"action,omitempty"`
+	// StatusCode of denied requests, 403 by default.
+	StatusCode int `json:"statusCode,omitempty"`
+}
+
+// lastLocation the content of the travel cookie.
+// The coordinates are kept at full precision, the geohash header may be too coarse to measure distances.
+type lastLocation struct {
+	CountryCode string  `json:"cc"`
+	Latitude    float64 `json:"lat"`
+	Longitude   float64 `json:"lng"`
+	Time        int64   `json:"t"`
+}
+
+// travelDetector tracks the location of sessions in an encrypted cookie.
+type travelDetector struct {
+	aead          cipher.AEAD
+	maxSpeedKmh   float64
+	minDistanceKm float64
+	cookieName    string
+	cookieMaxAge  time.Duration
+	action        string
+	status        int
+	now           func() time.Time
+}
+
+// newTravelDetector validates the config and creates the detector. Returns nil when detection is disabled.
+func newTravelDetector(cfg TravelConfig) (*travelDetector, error) {
+	if cfg.Secret == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	// AES-256-GCM both encrypts and authenticates the cookie.
+	key := sha256.Sum256([]byte(cfg.Secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	d := &travelDetector{
+		aead:          aead,
+		maxSpeedKmh:   cfg.MaxSpeedKmh,
+		minDistanceKm: cfg.MinDistanceKm,
+		cookieName:    cfg.CookieName,
+		cookieMaxAge:  defaultTravelCookieMaxAge,
+		action:        cfg.Action,
+		status:        cfg.StatusCode,
+		now:           time.Now,
+	}
+	if d.maxSpeedKmh < 0 || d.minDistanceKm < 0 {
+		return nil, fmt.Errorf("travel speed and distance must not be negative")
+	}
+	if d.maxSpeedKmh == 0 {
+		d.maxSpeedKmh = defaultTravelMaxSpeedKmh
+	}
+	if d.minDistanceKm == 0 {
+		d.minDistanceKm = defaultTravelMinDistanceKm
+	}
+	if d.cookieName == "" {
+		d.cookieName = defaultTravelCookie
+	}
+	if cfg.CookieMaxAge != "" {
+		if d.cookieMaxAge, err = time.ParseDuration(cfg.CookieMaxAge); err != nil || d.cookieMaxAge <= 0 {
+			return nil, fmt.Errorf("invalid travel cookie max age: cookieMaxAge=%s", cfg.CookieMaxAge)
+		}
+	}
+	if d.action != "" && d.action != TravelActionDeny {
+		return nil, fmt.Errorf("invalid travel action: action=%s", d.action)
+	}
+	if d.status == 0 {
+		d.status = http.StatusForbidden
+	}
+	if d.status < 400 || d.status > 599 {
+		return nil, fmt.Errorf("invalid travel status code: statusCode=%d", d.status)
+	}
+
+	return d, nil
+}
+
+// seal encrypts a location into a cookie value.
+func (d *travelDetector) seal(location lastLocation) (string, error) {
+	plaintext, err := json.Marshal(location)
+	if err != nil {
+		return "", fmt.Errorf("%w", err)
+	}
+
+	nonce := make([]byte, d.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("%w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(d.aead.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+// open decrypts a cookie value into a location.
+func (d *travelDetector) open(value string) (*lastLocation, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(sealed) < d.aead.NonceSize() {
+		return nil, errInvalidTravelCookie
+	}
+
+	nonceSize := d.aead.NonceSize()
+	plaintext, err := d.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return nil, errInvalidTravelCookie
+	}
+
+	location := &lastLocation{}
+	if err := json.Unmarshal(plaintext, location); err != nil {
+		return nil, errInvalidTravelCookie
+	}
+	return location, nil
+}
+
+// checkTravel compares the request's location with the previous location of the session.
+// Impossible travel marks the request, and returns a decision when the action is "deny" and it is enforced.
+// Otherwise the cookie is updated with the current location, so later requests are compared with the latest one.
+// Denied requests keep the previous location, so retrying them does not clear the detection.
+func (mw *TraefikGeoIP) checkTravel(rw http.ResponseWriter, req *http.Request, result *GeoIPResult, bypassID string) *decision {
+	d := mw.travel
+	if d == nil || !result.hasCoordinates() {
+		return nil
+	}
+
+	now := d.now()
+
+	if cookie, err := req.Cookie(d.cookieName); err == nil {
+		last, err := d.open(cookie.Value)
+		if err != nil {
+			if mw.debug {
+				log.Printf("[geoip] travel cookie rejected: name=%s, err=%v", mw.name, err)
+			}
+		} else {
+			distance := haversineKm(last.Latitude, last.Longitude, result.lat, result.lng)
+
+			// Requests within the same second are treated as one second apart.
+			elapsed := now.Sub(time.Unix(last.Time, 0))
+			if elapsed < time.Second {
+				elapsed = time.Second
+			}
+
+			if distance >= d.minDistanceKm && distance/elapsed.Hours() > d.maxSpeedKmh {
+				req.Header.Set(ImpossibleTravelHeader, "true")
+				req.Header.Set(TravelFromHeader, last.CountryCode)
+				req.Header.Set(TravelDistanceHeader, strconv.FormatFloat(distance, 'f', 0, 64))
+				req.Header.Set(TravelElapsedHeader, strconv.FormatInt(int64(elapsed.Seconds()), 10))
+
+				if d.action == TravelActionDeny {
+					dec := &decision{status: d.status, action: decisionDeny, rule: travelRule}
+					if mw.enforce(req, dec, bypassID) {
+						return dec
+					}
+				}
+			}
+		}
+	}
+
+	value, err := d.seal(lastLocation{CountryCode: result.countryCode, Latitude: result.lat, Longitude: result.lng, Time: now.Unix()})
+	if err != nil {
+		if mw.debug {
+			log.Printf("[geoip] unable to seal travel cookie: name=%s, err=%v", mw.name, err)
+		}
+		return nil
+	}
+	http.SetCookie(rw, &http.Cookie{
+		Name:     d.cookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(d.cookieMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   requestScheme(req) == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newYorkResult() *GeoIPResult {
+	return &GeoIPResult{
+		country: "United States", countryCode: "US", region: "NY", city: "New York",
+		latitude: "40.7128", longitude: "-74.0060", geohash: "dr5regw3pg6f", lat: 40.7128, lng: -74.0060,
+	}
+}
+
+func TestImpossibleTravel(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Travel = TravelConfig{Secret: "s3cret"}
+
+	current := munichResult()
+	lookup := func(ip net.IP) (*GeoIPResult, error) {
+		copied := *current
+		return &copied, nil
+	}
+	instance, err := newMiddleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}), cfg, "traefik_geoip", lookup)
+	if err != nil {
+		t.Fatalf("Error creating %v", err)
+	}
+	now := time.Unix(1735689600, 0)
+	instance.travel.now = func() time.Time { return now }
+
+	serve := func(cookie *http.Cookie) (*httptest.ResponseRecorder, *http.Request) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		req.RemoteAddr = "188.193.88.199:9999"
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		recorder := httptest.NewRecorder()
+		instance.ServeHTTP(recorder, req)
+		return recorder, req
+	}
+	cookieOf := func(recorder *httptest.ResponseRecorder) *http.Cookie {
+		t.Helper()
+		cookies := recorder.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != defaultTravelCookie || !cookies[0].HttpOnly {
+			t.Fatalf("invalid travel cookie %v", cookies)
+		}
+		return cookies[0]
+	}
+
+	recorder, req := serve(nil)
+	if req.Header.Get(ImpossibleTravelHeader) != "" {
+		t.Fatalf("first request must not be flagged")
+	}
+	munich := cookieOf(recorder)
+
+	// Munich to New York in ten minutes.
+	current = newYorkResult()
+	now = now.Add(10 * time.Minute)
+	recorder, req = serve(munich)
+	assertStatus(t, recorder, http.StatusOK)
+	if req.Header.Get(ImpossibleTravelHeader) != "true" || req.Header.Get(TravelFromHeader) != "DE" ||
+		req.Header.Get(TravelDistanceHeader) != "6486" || req.Header.Get(TravelElapsedHeader) != "600" {
+		t.Fatalf("invalid travel headers %v", req.Header)
+	}
+	newYork := cookieOf(recorder)
+
+	// Back to Munich, after enough time to fly.
+	current = munichResult()
+	now = now.Add(10 * time.Hour)
+	_, req = serve(newYork)
+	if req.Header.Get(ImpossibleTravelHeader) != "" {
+		t.Fatalf("possible travel must not be flagged")
+	}
+
+	// Tampered cookies are ignored.
+	tampered := []byte(munich.Value)
+	tampered[len(tampered)/2] ^= 1
+	munich.Value = string(tampered)
+	current = newYorkResult()
+	_, req = serve(munich)
+	if req.Header.Get(ImpossibleTravelHeader) != "" {
+		t.Fatalf("tampered cookie must be ignored")
+	}
+}
+
+func TestImpossibleTravelDeny(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Travel = TravelConfig{Secret: "s3cret", Action: TravelActionDeny, MaxSpeedKmh: 500}
+	instance := newTestMiddleware(t, cfg, newYorkResult())
+
+	munich := munichResult()
+	value, err := instance.travel.seal(lastLocation{CountryCode: "DE", Latitude: munich.lat, Longitude: munich.lng, Time: time.Now().Add(-5 * time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("Error sealing %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.RemoteAddr = "188.193.88.199:9999"
+	req.AddCookie(&http.Cookie{Name: defaultTravelCookie, Value: value})
+	recorder := httptest.NewRecorder()
+	instance.ServeHTTP(recorder, req)
+	assertStatus(t, recorder, http.StatusForbidden)
+	if len(recorder.Result().Cookies()) != 0 {
+		t.Fatalf("denied requests must not update the cookie")
+	}
+	if instance.Metrics()["deny;rule="+travelRule] != 1 {
+		t.Fatalf("invalid metrics %v", instance.Metrics())
+	}
+
+	// Retrying with the previous cookie is still denied.
+	recorder = httptest.NewRecorder()
+	instance.ServeHTTP(recorder, req)
+	assertStatus(t, recorder, http.StatusForbidden)
+	if len(recorder.Result().Cookies()) != 0 || instance.Metrics()["deny;rule="+travelRule] != 2 {
+		t.Fatalf("retries must be denied, got cookies %v, metrics %v", recorder.Result().Cookies(), instance.Metrics())
+	}
+
+	for _, travel := range []TravelConfig{
+		{Secret: "s", Action: "block"},
+		{Secret: "s", CookieMaxAge: "forever"},
+		{Secret: "s", MaxSpeedKmh: -1},
+	} {
+		cfg.Travel = travel
+		if _, err := newMiddleware(nil, cfg, "traefik_geoip", staticLookup(munich)); err == nil {
+			t.Fatalf("Must fail on invalid travel config %v", travel)
+		}
+	}
+}
+
+func TestImpossibleTravelCoarseGeohash(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.GeohashPrecision = 2
+	cfg.Travel = TravelConfig{Secret: "s3cret"}
+	instance := newTestMiddleware(t, cfg, munichResult())
+	now := time.Unix(1735689600, 0)
+	instance.travel.now = func() time.Time { return now }
+
+	recorder, _ := serveRequest(instance, "http://example.com/")
+	cookies := recorder.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("invalid travel cookie %v", cookies)
+	}
+
+	// The same client 30 seconds later has not moved, whatever the geohash precision.
+	now = now.Add(30 * time.Second)
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.RemoteAddr = "188.193.88.199:9999"
+	req.AddCookie(cookies[0])
+	instance.ServeHTTP(httptest.NewRecorder(), req)
+	if req.Header.Get(GeohashHeader) != "u2" || req.Header.Get(ImpossibleTravelHeader) != "" {
+		t.Fatalf("a client that did not move must not be flagged %v", req.Header)
+	}
+}
+
+func TestImpossibleTravelReportRefreshesCookie(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Mode = ModeReport
+	cfg.Travel = TravelConfig{Secret: "s3cret", Action: TravelActionDeny}
+	instance := newTestMiddleware(t, cfg, newYorkResult())
+	now := time.Unix(1735689600, 0)
+	instance.travel.now = func() time.Time { return now }
+
+	munich := munichResult()
+	value, err := instance.travel.seal(lastLocation{CountryCode: "DE", Latitude: munich.lat, Longitude: munich.lng, Time: now.Add(-time.Minute).Unix()})
+	if err != nil {
+		t.Fatalf("Error sealing %v", err)
+	}
+
+	serve := func(cookie *http.Cookie) (*httptest.ResponseRecorder, *http.Request) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		req.RemoteAddr = "188.193.88.199:9999"
+		req.AddCookie(cookie)
+		recorder := httptest.NewRecorder()
+		instance.ServeHTTP(recorder, req)
+		return recorder, req
+	}
+
+	recorder, req := serve(&http.Cookie{Name: defaultTravelCookie, Value: value})
+	assertStatus(t, recorder, http.StatusOK)
+	if req.Header.Get(ImpossibleTravelHeader) != "true" || req.Header.Get(PolicyDecisionHeader) != "would-deny; rule="+travelRule {
+		t.Fatalf("invalid travel headers %v", req.Header)
+	}
+	cookies := recorder.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("reported requests must update the cookie %v", cookies)
+	}
+
+	// The next request is compared with the refreshed location.
+	now = now.Add(time.Minute)
+	_, req = serve(cookies[0])
+	if req.Header.Get(ImpossibleTravelHeader) != "" {
+		t.Fatalf("a client that did not move must not be flagged %v", req.Header)
+	}
+}
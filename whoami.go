@@ -0,0 +1,159 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	// WhoamiFormatJSON answers the self-lookup endpoint with a JSON object.
+	WhoamiFormatJSON = "json"
+	// WhoamiFormatText answers the self-lookup endpoint with key=value lines.
+	WhoamiFormatText = "text"
+	// defaultWhoamiCacheControl default Cache-Control of the self-lookup endpoint.
+	// The answer is specific to the caller, so shared caches must never store it.
+	defaultWhoamiCacheControl = "no-store"
+)
+
+// WhoamiConfig configures the optional self-lookup endpoint.
+type WhoamiConfig struct {
+	// Path the endpoint answers on. The endpoint is disabled when empty.
+	Path string `json:"path,omitempty"`
+	// Format is the default answer format, either "json" or "text". Can be overridden with ?format=.
+	Format string `json:"format,omitempty"`
+	// CORSOrigins lists the origins allowed to call the endpoint from a browser. "*" allows any origin.
+	CORSOrigins []string `json:"corsOrigins,omitempty"`
+	// CacheControl is sent as the Cache-Control header of every answer.
+	CacheControl string `json:"cacheControl,omitempty"`
+}
+
+// whoamiEndpoint answers requests with the caller's own IP and geo data.
+type whoamiEndpoint struct {
+	path         string
+	format       string
+	corsOrigins  map[string]bool
+	corsAny      bool
+	cacheControl string
+}
+
+// newWhoamiEndpoint creates the endpoint from its config. Returns nil when the endpoint is disabled.
+func newWhoamiEndpoint(cfg WhoamiConfig) (*whoamiEndpoint, error) {
+	if cfg.Path == "" {
+		return nil, nil //nolint:nilnil
+	}
+	if cfg.Format != "" && cfg.Format != WhoamiFormatJSON && cfg.Format != WhoamiFormatText {
+		return nil, fmt.Errorf("invalid whoami format: format=%s", cfg.Format)
+	}
+
+	endpoint := &whoamiEndpoint{
+		path:         cfg.Path,
+		format:       WhoamiFormatJSON,
+		corsOrigins:  map[string]bool{},
+		cacheControl: cfg.CacheControl,
+	}
+	if cfg.Format == WhoamiFormatText {
+		endpoint.format = WhoamiFormatText
+	}
+	for _, origin := range cfg.CORSOrigins {
+		if origin == "*" {
+			endpoint.corsAny = true
+		}
+		endpoint.corsOrigins[origin] = true
+	}
+
+	return endpoint, nil
+}
+
+// matches checks if the request targets the endpoint.
+func (e *whoamiEndpoint) matches(req *http.Request) bool {
+	return e != nil && req.URL.Path == e.path
+}
+
+// setCORSHeaders allows the request's origin to read the answer if it is allowed.
+func (e *whoamiEndpoint) setCORSHeaders(rw http.ResponseWriter, req *http.Request) {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+
+	switch {
+	case e.corsAny:
+		rw.Header().Set("Access-Control-Allow-Origin", "*")
+	case e.corsOrigins[origin]:
+		rw.Header().Set("Access-Control-Allow-Origin", origin)
+		rw.Header().Add("Vary", "Origin")
+	default:
+		return
+	}
+	rw.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+}
+
+// serveWhoami answers the self-lookup endpoint.
+// It goes through the exact same resolution as regular requests so the answer matches what backends see.
+func (mw *TraefikGeoIP) serveWhoami(rw http.ResponseWriter, req *http.Request) {
+	endpoint := mw.whoami
+	endpoint.setCORSHeaders(rw, req)
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodOptions:
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		rw.Header().Set("Allow", "GET, HEAD, OPTIONS")
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	ip, result := mw.resolve(req)
+	markSimulated(rw, req)
+
+	// Build the answer from the same fields that are sent downstream as headers.
+	fields := []geoField{}
+	if ip != nil {
+		fields = append(fields, geoField{name: "ip", value: ip.String()})
+	}
+	if result != nil {
+		fields = append(fields, result.fields()...)
+	}
+
+	format := endpoint.format
+	if requested := req.URL.Query().Get("format"); requested == WhoamiFormatJSON || requested == WhoamiFormatText {
+		format = requested
+	}
+
+	var body []byte
+	if format == WhoamiFormatText {
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		lines := strings.Builder{}
+		for _, field := range fields {
+			lines.WriteString(field.name + "=" + field.value + "\n")
+		}
+		body = []byte(lines.String())
+	} else {
+		rw.Header().Set("Content-Type", "application/json")
+		values := make(map[string]string, len(fields))
+		for _, field := range fields {
+			values[field.name] = field.value
+		}
+		var err error
+		if body, err = json.Marshal(values); err != nil {
+			if mw.debug {
+				log.Printf("[geoip] unable to encode whoami answer: name=%s, err=%v", mw.name, err)
+			}
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if endpoint.cacheControl != "" {
+		rw.Header().Set("Cache-Control", endpoint.cacheControl)
+	}
+	rw.WriteHeader(http.StatusOK)
+	if req.Method != http.MethodHead {
+		_, _ = rw.Write(body)
+	}
+}
@@ -0,0 +1,102 @@
+package traefik_geoip //nolint:revive,stylecheck
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWhoamiJSON(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Whoami.Path = "/cdn-cgi/trace"
+	instance := newTestMiddleware(t, cfg, munichResult())
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/cdn-cgi/trace", nil)
+	req.RemoteAddr = "188.193.88.199:9999"
+	recorder := httptest.NewRecorder()
+	instance.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("invalid status code %d", recorder.Code)
+	}
+	if recorder.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("invalid Cache-Control '%s'", recorder.Header().Get("Cache-Control"))
+	}
+
+	answer := map[string]string{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &answer); err != nil {
+		t.Fatalf("invalid JSON answer: %v", err)
+	}
+	if answer["ip"] != "188.193.88.199" || answer["countryCode"] != "DE" || answer["geohash"] != "u284p0rv0cje" {
+		t.Fatalf("invalid answer %v", answer)
+	}
+}
+
+func TestWhoamiText(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Whoami.Path = "/whoami"
+	instance := newTestMiddleware(t, cfg, munichResult())
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/whoami?format=text", nil)
+	req.Header.Set("X-Forwarded-For", "188.193.88.199")
+	recorder := httptest.NewRecorder()
+	instance.ServeHTTP(recorder, req)
+
+	expected := "ip=188.193.88.199\ncountry=Germany\ncountryCode=DE\nregion=BY\ncity=Munich\n" +
+		"latitude=48.1663\nlongitude=11.5683\ngeohash=u284p0rv0cje\n"
+	if recorder.Body.String() != expected {
+		t.Fatalf("invalid answer '%s'", recorder.Body.String())
+	}
+}
+
+func TestWhoamiExcludedIP(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Whoami.Path = "/whoami"
+	cfg.ExcludeIPs = []string{"10.0.0.0/8"}
+	instance := newTestMiddleware(t, cfg, munichResult())
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/whoami", nil)
+	req.RemoteAddr = "10.1.2.3:9999"
+	recorder := httptest.NewRecorder()
+	instance.ServeHTTP(recorder, req)
+
+	if recorder.Body.String() != "{}" {
+		t.Fatalf("excluded IPs must not be resolved, got '%s'", recorder.Body.String())
+	}
+}
+
+func TestWhoamiCORS(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Whoami.Path = "/whoami"
+	cfg.Whoami.CORSOrigins = []string{"https://app.example.com"}
+	instance := newTestMiddleware(t, cfg, munichResult())
+
+	req := httptest.NewRequest(http.MethodOptions, "http://localhost/whoami", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	recorder := httptest.NewRecorder()
+	instance.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("invalid preflight status code %d", recorder.Code)
+	}
+	if recorder.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Fatalf("origin must be allowed")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/whoami", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	recorder = httptest.NewRecorder()
+	instance.ServeHTTP(recorder, req)
+	if recorder.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("origin must not be allowed")
+	}
+}
+
+func TestWhoamiInvalidFormat(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.Whoami.Path = "/whoami"
+	cfg.Whoami.Format = "xml"
+	if _, err := newMiddleware(http.NotFoundHandler(), cfg, "traefik_geoip", staticLookup(munichResult())); err == nil {
+		t.Fatalf("Must fail on invalid format")
+	}
+}